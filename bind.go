@@ -0,0 +1,368 @@
+package tong
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/ming3000/tong/common"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// $--- bind ---
+// Bind fills the struct pointed by v from the request, then validates it:
+// the param, query, header and form fields by their tags, and the body by its content type.
+// A failure is an HTTPError of StatusBadRequest.
+func (c *Context) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bind: %T is not a pointer", v)
+	}
+	if err := c.bindBody(v); err != nil {
+		return err
+	}
+
+	if elem := rv.Elem(); elem.Kind() == reflect.Struct {
+		r := c.request
+		sources := map[string]func(name string) ([]string, bool){
+			"param": func(name string) ([]string, bool) {
+				for i, n := range c.paramNames {
+					if n == name {
+						return []string{c.paramValues[i]}, true
+					}
+				}
+				return nil, false
+			},
+			"query": func(name string) ([]string, bool) {
+				values, ok := r.URL.Query()[name]
+				return values, ok
+			},
+			"header": func(name string) ([]string, bool) {
+				values, ok := r.Header[http.CanonicalHeaderKey(name)]
+				return values, ok
+			},
+			"form": func(name string) ([]string, bool) {
+				values, ok := r.PostForm[name]
+				return values, ok
+			},
+		}
+		for _, tag := range []string{"param", "query", "header", "form"} {
+			if err := bindValues(elem, tag, sources[tag]); err != nil {
+				return NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+		} // for>
+	} // if>
+
+	if err := Validate(v); err != nil {
+		var errs ValidationErrors
+		if errors.As(err, &errs) {
+			return NewHTTPError(http.StatusBadRequest, "validation failed").SetDetails(errs).SetInternal(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Context) bindBody(v interface{}) error {
+	r := c.request
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	contentType := r.Header.Get(common.HeaderContentType)
+	var err error
+	switch {
+	case strings.HasPrefix(contentType, common.MIMEApplicationJSON):
+		err = json.NewDecoder(r.Body).Decode(v)
+	case strings.HasPrefix(contentType, common.MIMEApplicationXML), strings.HasPrefix(contentType, common.MIMETextXML):
+		err = xml.NewDecoder(r.Body).Decode(v)
+	case strings.HasPrefix(contentType, common.MIMEApplicationForm):
+		err = r.ParseForm()
+	case strings.HasPrefix(contentType, common.MIMEMultipartForm):
+		err = r.ParseMultipartForm(32 << 20)
+	default:
+		return NewHTTPError(http.StatusUnsupportedMediaType)
+	}
+	if err != nil && err != io.EOF {
+		return NewHTTPError(http.StatusBadRequest, "invalid body: "+err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+// fill the fields named by the tag, from the values of the source
+func bindValues(v reflect.Value, tag string, source func(name string) ([]string, bool)) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, fv := v.Type().Field(i), v.Field(i)
+		if field.Anonymous && fv.Kind() == reflect.Struct && field.Tag.Get(tag) == "" {
+			if err := bindValues(fv, tag, source); err != nil {
+				return err
+			}
+			continue
+		} // if>>
+		name := FieldName(field, tag)
+		if name == "" {
+			continue
+		}
+		values, ok := source(name)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if err := setValues(fv, values); err != nil {
+			return fmt.Errorf("%s %s: %v", tag, name, err)
+		}
+	} // for>
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setValues sets the field from the strings, a slice takes all of them.
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// $--- validate ---
+// FieldError is a failed rule of the validate tag.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors are the failed rules of a struct.
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Field + ": " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the fields of the struct pointed by v with their validate tags:
+// required, omitempty, min, max, len, oneof, email, url and pattern,
+// min, max and len are the value of a number, and the length of the others.
+// The rules check the zero values too, omitempty skips them, and a nil pointer skips all but required.
+// The nested structs are validated, and the fields are named by their binding tags.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	errs := make(ValidationErrors, 0)
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var patternCache = common.NewLRUCache(128)
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	for i := 0; i < v.NumField(); i++ {
+		field, fv := v.Type().Field(i), v.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("validate") == "" {
+			if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				validateStruct(fv, prefix, errs)
+			}
+			continue
+		} // if>>
+		name := prefix + bindingName(field)
+		rules := ParseValidateTag(field.Tag.Get("validate"))
+		if hasRule(rules, "omitempty") && fv.IsZero() {
+			rules = nil
+		}
+		for _, rule := range rules {
+			if message := checkRule(fv, rule); message != "" {
+				*errs = append(*errs, FieldError{Field: name, Rule: rule.Name, Param: rule.Param, Message: message})
+				break
+			}
+		} // for>>
+
+		// the nested structs
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			validateStruct(fv, name+".", errs)
+		}
+	} // for>
+}
+
+// the name of the field in the request
+func bindingName(field reflect.StructField) string {
+	for _, tag := range bindingTags {
+		if name := FieldName(field, tag); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// checkRule returns the message of the failed rule, or "" if it passes
+func checkRule(v reflect.Value, rule ValidateRule) string {
+	if rule.Name == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	// a nil pointer is an absent value
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch rule.Name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(rule.Param, 64)
+		if err != nil {
+			return ""
+		}
+		n, unit := measure(v)
+		switch {
+		case rule.Name == "min" && n < limit:
+			return fmt.Sprintf("must be at least %s%s", rule.Param, unit)
+		case rule.Name == "max" && n > limit:
+			return fmt.Sprintf("must be at most %s%s", rule.Param, unit)
+		case rule.Name == "len" && n != limit:
+			return fmt.Sprintf("must be exactly %s%s", rule.Param, unit)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(rule.Param) {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + rule.Param
+	case "email":
+		if addr, err := mail.ParseAddress(fmt.Sprint(v.Interface())); err != nil || addr.Name != "" {
+			return "must be an email address"
+		}
+	case "url":
+		if u, err := url.ParseRequestURI(fmt.Sprint(v.Interface())); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a URL"
+		}
+	case "pattern":
+		var p *regexp.Regexp
+		if cached := patternCache.Get(rule.Param); cached != nil {
+			p = cached.(*regexp.Regexp)
+		} else {
+			var err error
+			if p, err = regexp.Compile(rule.Param); err != nil {
+				return "has an invalid pattern"
+			}
+			patternCache.Set(rule.Param, p)
+		}
+		if !p.MatchString(fmt.Sprint(v.Interface())) {
+			return "must match " + rule.Param
+		}
+	}
+	return ""
+}
+
+// the value of a number, or the length of the others
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	}
+	return 0, ""
+}
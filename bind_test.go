@@ -0,0 +1,127 @@
+package tong_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type Paging struct {
+	Page int `query:"page" validate:"omitempty,min=1"`
+}
+
+type searchRequest struct {
+	Paging
+	Tags    []string `query:"tag" validate:"max=2"`
+	Token   string   `header:"X-Token" validate:"required"`
+	Keyword string   `json:"keyword" form:"keyword" validate:"required,min=2"`
+	Sort    *string  `json:"sort" validate:"oneof=asc desc"`
+}
+
+func bind(t *testing.T, r *http.Request, v interface{}) error {
+	t.Helper()
+	c, _ := tongtest.NewContext(tongtest.NewApp(), r)
+	return c.Bind(v)
+}
+
+func TestContext_Bind(t *testing.T) {
+	r := tongtest.NewClient(tongtest.NewApp()).GET("/search?page=2&tag=a&tag=b").
+		Header("X-Token", "t0").JSON(map[string]string{"keyword": "go", "sort": "asc"}).Build()
+	var req searchRequest
+	if err := bind(t, r, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Page != 2 || strings.Join(req.Tags, ",") != "a,b" || req.Token != "t0" || req.Keyword != "go" || *req.Sort != "asc" {
+		t.Errorf("req = %+v", req)
+	}
+
+	r = tongtest.NewClient(tongtest.NewApp()).POST("/search").Header("X-Token", "t0").
+		Form(url.Values{"keyword": {"tong"}}).Build()
+	req = searchRequest{}
+	if err := bind(t, r, &req); err != nil || req.Keyword != "tong" {
+		t.Errorf("req = %+v, err = %v", req, err)
+	}
+}
+
+func TestContext_BindErrors(t *testing.T) {
+	client := tongtest.NewClient(tongtest.NewApp())
+	var he *tong.HTTPError
+
+	err := bind(t, client.GET("/search?page=x").Build(), &searchRequest{})
+	if !errors.As(err, &he) || he.Code != http.StatusBadRequest || !strings.Contains(he.Message, "query page") {
+		t.Errorf("bad query: %v", err)
+	}
+	err = bind(t, client.POST("/search").Body(strings.NewReader("{"), "application/json").Build(), &searchRequest{})
+	if !errors.As(err, &he) || he.Code != http.StatusBadRequest {
+		t.Errorf("bad JSON: %v", err)
+	}
+	err = bind(t, client.POST("/search").Body(strings.NewReader("x"), "application/unknown").Build(), &searchRequest{})
+	if !errors.As(err, &he) || he.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("unknown content type: %v", err)
+	}
+
+	err = bind(t, client.GET("/search?page=-1&tag=a&tag=b&tag=c").JSON(map[string]string{"keyword": "g", "sort": "up"}).Build(), &searchRequest{})
+	if !errors.As(err, &he) || he.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request: %v", err)
+	}
+	errs, _ := he.Details.(tong.ValidationErrors)
+	want := map[string]string{"page": "min", "tag": "max", "X-Token": "required", "keyword": "min", "sort": "oneof"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v", errs)
+	}
+	for _, e := range errs {
+		if want[e.Field] != e.Rule {
+			t.Errorf("error = %+v", e)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type contact struct {
+		Email   string `json:"email" validate:"email"`
+		Website string `json:"website" validate:"url"`
+		Code    string `json:"code" validate:"len=4,pattern=^[0-9]+$"`
+	}
+	type profile struct {
+		Name    string   `json:"name" validate:"required"`
+		Age     int      `json:"age" validate:"min=0,max=150"`
+		Contact *contact `json:"contact"`
+	}
+
+	if err := tong.Validate(&profile{Name: "tom", Contact: &contact{Email: "tom@example.com", Website: "https://example.com", Code: "0042"}}); err != nil {
+		t.Errorf("valid profile: %v", err)
+	}
+	err := tong.Validate(&profile{Age: 200, Contact: &contact{Email: "tom", Website: "example", Code: "00a2"}})
+	var errs tong.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v", err)
+	}
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	if got := strings.Join(fields, ","); got != "name,age,contact.email,contact.website,contact.code" {
+		t.Errorf("fields = %s, errors: %v", got, err)
+	}
+
+	// the zero values are checked unless omitempty, and a pattern may contain commas
+	type query struct {
+		Page  int    `query:"page" validate:"min=1"`
+		Size  int    `query:"size" validate:"omitempty,min=10"`
+		Sort  string `query:"sort" validate:"oneof=asc desc"`
+		Order string `query:"order" validate:"omitempty,oneof=asc desc"`
+		Code  string `query:"code" validate:"pattern=^[a-z]{2,3}$"`
+	}
+	err = tong.Validate(&query{Code: "abcd"})
+	if !errors.As(err, &errs) || errs.Error() != "page: must be at least 1; sort: must be one of asc desc; code: must match ^[a-z]{2,3}$" {
+		t.Errorf("err = %v", err)
+	}
+	if err := tong.Validate(&query{Page: 1, Sort: "asc", Code: "abc"}); err != nil {
+		t.Errorf("valid query: %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ming3000/tong"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// the files watched by `tong dev`, and what their changes do
+const (
+	changeNone = iota
+	// the static files are reloaded by the browser
+	changeReload
+	// the templates are loaded by the app on its start
+	changeRestart
+	// the sources are built
+	changeRebuild
+)
+
+func changeOf(path string) int {
+	switch base := filepath.Base(path); {
+	case strings.HasSuffix(base, ".go") && !strings.HasSuffix(base, "_test.go"), base == "go.mod", base == "go.sum":
+		return changeRebuild
+	case strings.HasSuffix(base, ".html"), strings.HasSuffix(base, ".tmpl"), strings.HasSuffix(base, ".tpl"),
+		strings.HasSuffix(base, ".gohtml"), strings.HasSuffix(base, ".yaml"), strings.HasSuffix(base, ".yml"),
+		strings.HasSuffix(base, ".toml"), strings.HasSuffix(base, ".json"):
+		return changeRestart
+	case strings.HasSuffix(base, ".css"), strings.HasSuffix(base, ".js"):
+		return changeReload
+	}
+	return changeNone
+}
+
+// runDev builds and runs the app package, and rebuilds and restarts it on the changes of its files,
+// the listener is kept by the command and handed to each process of the app,
+// so the requests are not refused during the restarts.
+func runDev(args []string) error {
+	flags := flag.NewFlagSet("dev", flag.ContinueOnError)
+	addr := flags.String("addr", ":3000", "the address the app serves on")
+	reloadAddr := flags.String("reload-addr", "127.0.0.1:35729", "the address of the live reload events")
+	interval := flags.Duration("interval", 500*time.Millisecond, "the interval of polling the files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	pkg, appArgs := ".", flags.Args()
+	if len(appArgs) > 0 && appArgs[0] != "--" {
+		pkg, appArgs = appArgs[0], appArgs[1:]
+	}
+	if len(appArgs) > 0 && appArgs[0] == "--" {
+		appArgs = appArgs[1:]
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	listener, err := l.(*net.TCPListener).File()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	events := newReloadEvents()
+	reloadListener, err := net.Listen("tcp", *reloadAddr)
+	if err != nil {
+		return err
+	}
+	go func() { _ = http.Serve(reloadListener, events) }()
+
+	binDir, err := os.MkdirTemp("", "tong-dev-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(binDir)
+
+	d := &devServer{
+		pkg:       pkg,
+		args:      appArgs,
+		bin:       filepath.Join(binDir, "app"),
+		listener:  listener,
+		reloadURL: "http://" + reloadListener.Addr().String() + "/livereload",
+		events:    events,
+	}
+	fmt.Fprintf(os.Stderr, "tong dev: serving %s on %s\n", pkg, l.Addr())
+	if err := d.build(); err == nil {
+		d.restart()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	watcher := newTreeWatcher(".", *interval)
+	for {
+		select {
+		case <-signals:
+			d.stop()
+			return nil
+		case change := <-watcher.changes:
+			switch change {
+			case changeRebuild:
+				if err := d.build(); err != nil {
+					continue
+				}
+				d.restart()
+			case changeRestart:
+				d.restart()
+			}
+			events.send("reload")
+		}
+	} // for>
+}
+
+// $--- app process ---
+type devServer struct {
+	pkg       string
+	args      []string
+	bin       string
+	listener  *os.File
+	reloadURL string
+	events    *reloadEvents
+	cmd       *exec.Cmd
+	exited    chan struct{}
+}
+
+// build the app, the errors are printed, and the running app is kept
+func (d *devServer) build() error {
+	cmd := exec.Command("go", "build", "-o", d.bin, d.pkg)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "tong dev: build failed: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "tong dev: built in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// restart starts a new process of the app on the listener, then stops the old one,
+// which closes its copy of the listener and finishes its requests.
+func (d *devServer) restart() {
+	cmd := exec.Command(d.bin, d.args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// the first of the extra files is the fd 3
+	cmd.ExtraFiles = []*os.File{d.listener}
+	cmd.Env = append(os.Environ(), tong.ListenFDEnv+"=3", tong.LiveReloadEnv+"="+d.reloadURL)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "tong dev: start failed: %v\n", err)
+		return
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	d.stop()
+	d.cmd, d.exited = cmd, exited
+}
+
+// stop the running app, it is killed if it does not exit in time
+func (d *devServer) stop() {
+	if d.cmd == nil {
+		return
+	}
+	if err := d.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = d.cmd.Process.Kill()
+	}
+	select {
+	case <-d.exited:
+	case <-time.After(15 * time.Second):
+		_ = d.cmd.Process.Kill()
+		<-d.exited
+	}
+	d.cmd = nil
+}
+
+// $--- live reload events ---
+// reloadEvents sends the events to the pages, as server-sent events
+type reloadEvents struct {
+	lock    sync.Mutex
+	clients map[chan string]struct{}
+}
+
+func newReloadEvents() *reloadEvents {
+	return &reloadEvents{clients: make(map[chan string]struct{})}
+}
+
+func (e *reloadEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// the pages are of the app, on another origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	client := make(chan string, 1)
+	e.lock.Lock()
+	e.clients[client] = struct{}{}
+	e.lock.Unlock()
+	defer func() {
+		e.lock.Lock()
+		delete(e.clients, client)
+		e.lock.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-client:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	} // for>
+}
+
+// send the event to the pages, the slow ones miss it
+func (e *reloadEvents) send(event string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for client := range e.clients {
+		select {
+		case client <- event:
+		default:
+		}
+	} // for>
+}
+
+// $--- watcher ---
+// treeWatcher polls the modification times of the files in a tree,
+// and sends the most significant change of each round.
+type treeWatcher struct {
+	root     string
+	interval time.Duration
+	files    map[string]time.Time
+	changes  chan int
+}
+
+func newTreeWatcher(root string, interval time.Duration) *treeWatcher {
+	w := &treeWatcher{root: root, interval: interval, changes: make(chan int)}
+	w.files, _ = w.scan()
+	go w.run()
+	return w
+}
+
+func (w *treeWatcher) run() {
+	for {
+		time.Sleep(w.interval)
+		files, err := w.scan()
+		if err != nil {
+			continue
+		}
+		change := changeNone
+		for path, modTime := range files {
+			if old, ok := w.files[path]; !ok || !old.Equal(modTime) {
+				change = maxChange(change, changeOf(path))
+			}
+		} // for>>
+		for path := range w.files {
+			if _, ok := files[path]; !ok {
+				change = maxChange(change, changeOf(path))
+			}
+		} // for>>
+		w.files = files
+		if change != changeNone {
+			w.changes <- change
+		}
+	} // for>
+}
+
+func maxChange(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// scan the watched files, the hidden dirs, vendor and node_modules are skipped
+func (w *treeWatcher) scan() (map[string]time.Time, error) {
+	files := make(map[string]time.Time)
+	err := filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != w.root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		} // if>
+		if changeOf(path) != changeNone {
+			files[path] = info.ModTime()
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"unicode"
+)
+
+// the files generated of each kind, the tests are skipped by -notest
+var generators = map[string][]string{
+	"handler":    {"handler.go", "handler_test.go"},
+	"middleware": {"middleware.go", "middleware_test.go"},
+	"job":        {"job.go", "job_test.go"},
+}
+
+// runGen generates a HandlerFunc, a MiddlewareFunc or a common.Job with its test.
+func runGen(args []string) error {
+	if len(args) == 0 || generators[args[0]] == nil {
+		return errors.New("usage: tong gen handler|middleware|job [-dir d] [-pkg p] [-notest] <Name>")
+	}
+	kind := args[0]
+	flags := flag.NewFlagSet("gen "+kind, flag.ContinueOnError)
+	dir := flags.String("dir", defaultGenDirs[kind], "the dir of the generated files")
+	pkg := flags.String("pkg", "", "the package name, it defaults to the package in the dir, or the name of the dir")
+	noTest := flags.Bool("notest", false, "do not generate the test")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: tong gen %s [-dir d] [-pkg p] [-notest] <Name>", kind)
+	}
+	name := flags.Arg(0)
+	if !token.IsIdentifier(name) || !token.IsExported(name) {
+		return fmt.Errorf("%q is not an exported Go identifier", name)
+	}
+
+	data := templateData{Name: name, Package: *pkg}
+	if data.Package == "" {
+		data.Package = packageName(*dir)
+	}
+	base := snakeCase(name)
+	for _, file := range generators[kind] {
+		test := file == kind+"_test.go"
+		if test && *noTest {
+			continue
+		}
+		target := base + ".go"
+		if test {
+			target = base + "_test.go"
+		}
+		if err := render("gen/"+file+".tmpl", filepath.Join(*dir, target), data); err != nil {
+			return err
+		}
+	} // for>
+	return nil
+}
+
+var defaultGenDirs = map[string]string{
+	"handler":    "handlers",
+	"middleware": "middleware",
+	"job":        "jobs",
+}
+
+// the package of the Go files in the dir, or the name of the dir
+func packageName(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return f.Name.Name
+		}
+	} // for>
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return sanitizePackage(filepath.Base(dir))
+}
+
+// a package name of the letters and digits of s, in lower case
+func sanitizePackage(s string) string {
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		if unicode.IsLetter(r) || (unicode.IsDigit(r) && len(runes) > 0) {
+			runes = append(runes, unicode.ToLower(r))
+		}
+	} // for>
+	if len(runes) == 0 {
+		return "main"
+	}
+	return string(runes)
+}
+
+// CreateUser is create_user, and UserID is user_id
+func snakeCase(s string) string {
+	runes := []rune(s)
+	out := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				out = append(out, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out = append(out, r)
+	} // for>
+	return string(out)
+}
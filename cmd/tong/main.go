@@ -0,0 +1,52 @@
+// Command tong scaffolds and inspects Tong applications.
+//
+//	tong new [-module path] <dir>                   create a project skeleton
+//	tong routes [-json] [package] [-- args]          print the route table of the app
+//	tong dev [-addr :3000] [package] [-- args]       run the app, rebuild and restart it on changes
+//	tong gen handler|middleware|job [-dir d] <Name>  generate a handler, middleware or job
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+)
+
+//go:embed all:templates
+var templates embed.FS
+
+const usage = `usage: tong <command> [arguments]
+
+commands:
+  new [-module path] <dir>                   create a project skeleton
+  routes [-json] [package] [-- args]         print the route table of the app
+  dev [-addr :3000] [package] [-- args]      run the app, rebuild and restart it on changes
+  gen handler|middleware|job [-dir d] <Name> generate a handler, middleware or job
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "new":
+		err = runNew(args)
+	case "routes":
+		err = runRoutes(args)
+	case "gen":
+		err = runGen(args)
+	case "dev":
+		err = runDev(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "tong: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tong:", err)
+		os.Exit(1)
+	}
+}
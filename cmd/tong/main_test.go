@@ -0,0 +1,122 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// parse checks the Go file, and returns its package name
+func parse(t *testing.T, path string) string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Name.Name
+}
+
+func TestRunNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shop")
+	if err := runNew([]string{"-module", "example.com/shop", dir}); err != nil {
+		t.Fatal(err)
+	}
+	for file, pkg := range map[string]string{"main.go": "main", "handlers/handlers.go": "handlers", "handlers/hello_test.go": "handlers"} {
+		if got := parse(t, filepath.Join(dir, file)); got != pkg {
+			t.Errorf("package of %s = %s, want %s", file, got, pkg)
+		}
+	} // for>
+	mod, _ := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if string(mod[:len("module example.com/shop\n")]) != "module example.com/shop\n" {
+		t.Errorf("go.mod = %s", mod)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); err != nil {
+		t.Error(err)
+	}
+
+	if err := runNew([]string{dir}); err == nil {
+		t.Error("a project is created in a dir not empty")
+	}
+}
+
+func TestRunGen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.go"), []byte("package api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGen([]string{"handler", "-dir", dir, "GetUserID"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"get_user_id.go", "get_user_id_test.go"} {
+		if pkg := parse(t, filepath.Join(dir, file)); pkg != "api" {
+			t.Errorf("package of %s = %s", file, pkg)
+		}
+	} // for>
+	if err := runGen([]string{"handler", "-dir", dir, "GetUserID"}); err == nil {
+		t.Error("the handler is overwritten")
+	}
+
+	jobs := filepath.Join(dir, "cron-jobs")
+	if err := runGen([]string{"job", "-dir", jobs, "-notest", "Cleanup"}); err != nil {
+		t.Fatal(err)
+	}
+	if pkg := parse(t, filepath.Join(jobs, "cleanup.go")); pkg != "cronjobs" {
+		t.Errorf("package = %s", pkg)
+	}
+	if _, err := os.Stat(filepath.Join(jobs, "cleanup_test.go")); !os.IsNotExist(err) {
+		t.Error("the test is generated with -notest")
+	}
+
+	if err := runGen([]string{"middleware", "-dir", dir, "auth"}); err == nil {
+		t.Error("an unexported name is generated")
+	}
+	if err := runGen([]string{"model", "User"}); err == nil {
+		t.Error("an unknown kind is generated")
+	}
+}
+
+func TestTreeWatcher(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main\n")
+	write("static/app.css", "body {}")
+	w := newTreeWatcher(dir, 10*time.Millisecond)
+	expect := func(want int) {
+		t.Helper()
+		select {
+		case got := <-w.changes:
+			if got != want {
+				t.Errorf("change = %d, want %d", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no change, want %d", want)
+		}
+	}
+
+	write("static/app.css", "body { margin: 0 }")
+	expect(changeReload)
+	write("views/index.html", "<p></p>")
+	expect(changeRestart)
+	write("handlers.go", "package main\n")
+	expect(changeRebuild)
+	// the tests, the unknown files and the hidden dirs are skipped
+	write("main_test.go", "package main\n")
+	write("README.md", "# app")
+	write(".git/HEAD", "ref")
+	select {
+	case got := <-w.changes:
+		t.Errorf("change = %d, want none", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// runNew creates a project skeleton in the dir:
+// the main with its config, the handlers and their tests.
+func runNew(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := flags.String("module", "", "the module path, it defaults to the name of the dir")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: tong new [-module path] <dir>")
+	}
+	dir := flags.Arg(0)
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+
+	data := templateData{App: filepath.Base(dir), Module: *module, Version: tongVersion()}
+	if data.Module == "" {
+		data.Module = data.App
+	}
+	err := fs.WalkDir(templates, "templates/new", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := strings.TrimPrefix(path, "templates/")
+		target := strings.TrimSuffix(strings.TrimPrefix(name, "new/"), ".tmpl")
+		if target == "gitignore" {
+			target = ".gitignore"
+		}
+		return render(name, filepath.Join(dir, filepath.FromSlash(target)), data)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nnext steps:\n  cd %s\n  go mod tidy\n  go run .\n", dir)
+	return nil
+}
+
+// the version of the tong module the command is installed with, or "" if it is unknown,
+// e.g. a local build of a modified tree
+func tongVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || !strings.HasPrefix(info.Main.Version, "v") || strings.Contains(info.Main.Version, "+") {
+		return ""
+	}
+	return info.Main.Version
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is the data of the templates
+type templateData struct {
+	App     string
+	Module  string
+	Version string
+	Package string
+	Name    string
+}
+
+// render writes the template to path, the Go files are formatted,
+// an existing file is not overwritten.
+func render(name, path string, data templateData) error {
+	text, err := templates.ReadFile("templates/" + name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(name).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if strings.HasSuffix(path, ".go") {
+		if out, err = format.Source(out); err != nil {
+			return fmt.Errorf("format %s: %v", path, err)
+		}
+	} // if>
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return err
+	}
+	fmt.Println("create", path)
+	return nil
+}
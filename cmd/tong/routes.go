@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ming3000/tong"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+)
+
+// runRoutes runs the app package in the inspection mode, and prints its route table,
+// the app writes its routes when it starts the server, see tong.InspectRoutesEnv.
+func runRoutes(args []string) error {
+	flags := flag.NewFlagSet("routes", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the routes as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	pkg, appArgs := ".", flags.Args()
+	if len(appArgs) > 0 && appArgs[0] != "--" {
+		pkg, appArgs = appArgs[0], appArgs[1:]
+	}
+	if len(appArgs) > 0 && appArgs[0] == "--" {
+		appArgs = appArgs[1:]
+	}
+
+	f, err := os.CreateTemp("", "tong-routes-*.json")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	_ = f.Close()
+	defer os.Remove(path)
+
+	cmd := exec.Command("go", append([]string{"run", pkg}, appArgs...)...)
+	cmd.Env = append(os.Environ(), tong.InspectRoutesEnv+"="+path)
+	// the output of the app is not the table
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run %s: %v", pkg, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.New("the app exited without starting the server")
+	}
+	if *asJSON {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	var routes []tong.RouteEntry
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tNAME\tSUMMARY")
+	for _, r := range routes {
+		summary := r.Summary
+		if r.Hidden {
+			summary = strings.TrimSpace(summary + " (hidden)")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Method, r.Path, r.Name, summary)
+	} // for>
+	return w.Flush()
+}
@@ -0,0 +1,208 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what to do when the async buffer is full.
+type OverflowPolicy int
+
+const (
+	// block the caller until there is room
+	OverflowBlock OverflowPolicy = iota
+	// drop the record being logged
+	OverflowDropNewest
+	// drop the oldest buffered record
+	OverflowDropOldest
+)
+
+// AsyncOptions are the options of the asynchronous mode, zero fields take defaults.
+type AsyncOptions struct {
+	BufferSize    int
+	Policy        OverflowPolicy
+	FlushInterval time.Duration
+}
+
+// EnableAsync writes the records from a background goroutine,
+// the callers only encode the records into a ring buffer.
+func (l *Logger) EnableAsync(options AsyncOptions) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = 4096
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+
+	l.core.writeLock.Lock()
+	defer l.core.writeLock.Unlock()
+	if l.core.async != nil {
+		return
+	} // if>
+	a := &asyncWriter{
+		core:    l.core,
+		records: make([]asyncRecord, options.BufferSize),
+		policy:  options.Policy,
+		stop:    make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.lock)
+	l.core.async = a
+	a.done.Add(2)
+	go a.run()
+	l.core.lock.RLock()
+	go a.flush(l.core.clock.NewTicker(options.FlushInterval))
+	l.core.lock.RUnlock()
+}
+
+// Close writes out the repeat counts and the buffered records, stops the goroutines of the async mode,
+// and syncs the sinks. The logger is still usable, the later records are written synchronously.
+func (l *Logger) Close() error {
+	l.core.flushDedupers(nil)
+	l.core.writeLock.Lock()
+	async := l.core.async
+	l.core.async = nil
+	l.core.writeLock.Unlock()
+
+	if async != nil {
+		async.close()
+	} // if>
+	return l.core.sync()
+}
+
+// Dropped returns the number of records dropped by the overflow policy.
+func (l *Logger) Dropped() uint64 {
+	l.core.writeLock.Lock()
+	async := l.core.async
+	l.core.writeLock.Unlock()
+
+	if async == nil {
+		return 0
+	} // if>
+	async.lock.Lock()
+	defer async.lock.Unlock()
+	return async.dropped
+}
+
+type asyncRecord struct {
+	level  Level
+	record []byte
+}
+
+// asyncWriter is a ring buffer of the encoded records
+type asyncWriter struct {
+	core    *logCore
+	records []asyncRecord
+	head    int
+	size    int
+	writing bool
+	// written is set when the records are written, and cleared by the flush
+	written bool
+	closed  bool
+	policy  OverflowPolicy
+	dropped uint64
+	stop    chan struct{}
+	done    sync.WaitGroup
+	lock    sync.Mutex
+	cond    *sync.Cond
+}
+
+func (a *asyncWriter) push(level Level, record []byte) {
+	a.lock.Lock()
+	for a.size == len(a.records) && !a.closed {
+		switch a.policy {
+		case OverflowDropNewest:
+			a.dropped++
+			a.lock.Unlock()
+			return
+		case OverflowDropOldest:
+			a.dropped++
+			a.head = (a.head + 1) % len(a.records)
+			a.size--
+		default:
+			a.cond.Wait()
+		}
+	} // for>
+	if a.closed {
+		a.lock.Unlock()
+		// closed meanwhile, written in place
+		a.core.write(level, record)
+		return
+	} // if>
+	a.records[(a.head+a.size)%len(a.records)] = asyncRecord{level: level, record: record}
+	a.size++
+	a.cond.Broadcast()
+	a.lock.Unlock()
+}
+
+// run writes the buffered records to the sinks, until it is closed and drained
+func (a *asyncWriter) run() {
+	defer a.done.Done()
+	batch := make([]asyncRecord, 0, len(a.records))
+	for {
+		a.lock.Lock()
+		for a.size == 0 {
+			a.writing = false
+			a.cond.Broadcast()
+			if a.closed {
+				a.lock.Unlock()
+				return
+			} // if>>>
+			a.cond.Wait()
+		} // for>>
+		a.writing = true
+		batch = batch[:0]
+		for ; a.size > 0; a.size-- {
+			batch = append(batch, a.records[a.head])
+			a.records[a.head] = asyncRecord{}
+			a.head = (a.head + 1) % len(a.records)
+		} // for>>
+		a.cond.Broadcast()
+		a.lock.Unlock()
+
+		for _, r := range batch {
+			a.core.write(r.level, r.record)
+		} // for>>
+		a.lock.Lock()
+		a.written = true
+		a.lock.Unlock()
+	} // for>
+}
+
+// flush syncs the sinks periodically, if any record was written
+func (a *asyncWriter) flush(ticker Ticker) {
+	defer a.done.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-a.stop:
+			return
+		}
+		a.lock.Lock()
+		written := a.written
+		a.written = false
+		a.lock.Unlock()
+		if written {
+			_ = a.core.sync()
+		} // if>>
+	} // for>
+}
+
+// close drains the buffered records and stops the goroutines
+func (a *asyncWriter) close() {
+	a.lock.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.lock.Unlock()
+	close(a.stop)
+	a.done.Wait()
+}
+
+// drain waits until the buffered records are written
+func (a *asyncWriter) drain() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for a.size > 0 || a.writing {
+		a.cond.Wait()
+	} // for>
+}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time, it is injected wherever time matters,
+// so the behaviour can be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f once d has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks of a Clock at intervals.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer calls a function once at a time of a Clock.
+type Timer interface {
+	// Stop prevents the call, it returns false if the call is done or stopped
+	Stop() bool
+}
+
+// --- system clock ---
+// SystemClock is the Clock of the operating system.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (s systemTicker) C() <-chan time.Time {
+	return s.ticker.C
+}
+
+func (s systemTicker) Stop() {
+	s.ticker.Stop()
+}
+
+// --- fake clock ---
+// FakeClock is a Clock which only moves when it is advanced manually.
+type FakeClock struct {
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []*fakeTimer
+	lock    sync.Mutex
+}
+
+// NewFakeClock returns a new FakeClock instance starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	t := &fakeTicker{clock: f, period: d, next: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// AfterFunc calls f when the clock is advanced by d,
+// in the goroutine of Advance, so the call is done once Advance returns.
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	t := &fakeTimer{clock: f, when: f.now.Add(d), f: fn}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the tickers and the timers on the way.
+// like time.Ticker, the ticks are dropped for a slow receiver.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.lock.Lock()
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		for !t.next.After(f.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		} // for>>
+	} // for>
+
+	due := make([]*fakeTimer, 0)
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.when.After(f.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		} // else>>
+	} // for>
+	f.timers = pending
+	f.lock.Unlock()
+
+	// the functions may use the clock
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, t := range due {
+		t.f()
+	} // for>
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	for i, v := range t.clock.tickers {
+		if v == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		} // if>>
+	} // for>
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	for i, v := range t.clock.timers {
+		if v == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		} // if>>
+	} // for>
+	return false
+}
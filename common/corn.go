@@ -1,6 +1,10 @@
 package common
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
 
 // Job is an interface for job to do.
 type Job interface {
@@ -18,7 +22,10 @@ type Cron struct {
 	prev          time.Time
 	next          time.Time
 	job           Job
-	wait          chan bool
+	name          string
+	locker        Locker
+	history       HistoryStore
+	clock         Clock
 	stop          chan struct{}
 	running       bool
 }
@@ -37,6 +44,35 @@ func (c *Cron) Every(period time.Duration) *Cron {
 // Set the job.
 func (c *Cron) Do(job Job) *Cron {
 	c.job = job
+	if c.name == "" {
+		c.name = fmt.Sprintf("%T", job)
+	}
+	return c
+}
+
+// Name set the name of the job, it defaults to the type name of the job.
+func (c *Cron) Name(name string) *Cron {
+	c.name = name
+	return c
+}
+
+// JobName returns the name of the job, which keys its locks and its run records.
+func (c *Cron) JobName() string {
+	return c.name
+}
+
+// SetLocker set the locker consulted before each run,
+// so a job runs on exactly one instance per schedule slot, and re-schedules the job.
+func (c *Cron) SetLocker(locker Locker) *Cron {
+	c.locker = locker
+	c.schedule(c.period)
+	return c
+}
+
+// SetClock set the clock driving the schedule, and re-schedules the job.
+func (c *Cron) SetClock(clock Clock) *Cron {
+	c.clock = clock
+	c.schedule(c.period)
 	return c
 }
 
@@ -47,52 +83,63 @@ func (c *Cron) Start() {
 		return
 	}
 	c.running = true
-	go c.run()
+	c.stop = make(chan struct{})
+	go c.run(c.clock.NewTicker(1*time.Second), c.stop)
 }
 
-// Stop the CronJob if it is running.
+// Stop the CronJob if it is running,
+// a running job is not waited, and no job starts after Stop.
 func (c *Cron) Stop() {
 	if !c.running {
 		return
 	}
-	c.stop <- struct{}{}
+	close(c.stop)
 	c.running = false
 }
 
 func (c *Cron) schedule(period time.Duration) {
 	c.period = period
-	c.prev = time.Now()
+	c.prev = c.clock.Now()
 	c.next = c.prev.Add(period)
+	// with a locker, the runs are aligned to the slots of the initial period,
+	// so the instances started at different times contend in the same slot
+	if c.locker != nil {
+		c.next = c.prev.Truncate(c.initialPeriod).Add(period)
+	}
 }
 
-func (c *Cron) run() {
-	ticker := time.NewTicker(1 * time.Second)
-	var ifWait bool
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				if !ifWait {
-					go c.runJob()
-				}
-				continue
-			case <-c.stop:
-				ticker.Stop()
-				return
-			case ifWait = <-c.wait:
-				//public.Debug.Println("get msg from the job: ", ifWait, time.Now().Format(time.RFC3339))
-				continue
-			}
+// run checks the schedule every second until stopped,
+// the ticks are dropped while the job is running.
+func (c *Cron) run(ticker Ticker, stop <-chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-stop:
+			return
 		}
-	}()
+		// a tick and a stop may be ready together
+		select {
+		case <-stop:
+			return
+		default:
+			c.runJob()
+		}
+	}
 }
 
 func (c *Cron) runJob() {
-	if time.Now().After(c.next) {
-		c.wait <- true
+	if c.clock.Now().After(c.next) {
 		// set-schedule before this job is done
 		c.schedule(c.period)
-		ifDecline := c.job.Run()
+		if !c.acquire() {
+			return
+		}
+		record := RunRecord{Job: c.name, Start: c.clock.Now(), Result: RunSuccess}
+		ifDecline, err := c.runSafe()
+		if err != nil {
+			record.Result, record.Error = RunPanic, err.Error()
+		}
 		if ifDecline {
 			next := c.period + c.stepPeriod
 			if next >= c.maxPeriod {
@@ -104,8 +151,49 @@ func (c *Cron) runJob() {
 			// re-schedule after this job is done
 			c.schedule(c.initialPeriod)
 		}
-		c.wait <- false
+		c.record(record, ifDecline)
+	}
+}
+
+// run the job, a panic is recovered as an error
+func (c *Cron) runSafe() (ifDecline bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return c.job.Run(), nil
+}
+
+// append the record to the history store if any
+func (c *Cron) record(record RunRecord, ifDecline bool) {
+	if c.history == nil {
+		return
+	}
+	record.End = c.clock.Now()
+	record.Duration = record.End.Sub(record.Start)
+	record.Declined = ifDecline
+	record.NextPeriod = c.period
+	_ = c.history.Append(record)
+}
+
+// acquire the lock of current schedule slot,
+// the slot is the time truncated to the initial period, so it is the same on every instance
+// and it does not move with the backoff.
+func (c *Cron) acquire() bool {
+	if c.locker == nil {
+		return true
 	}
+	slot := c.clock.Now().Truncate(c.initialPeriod)
+	key := c.name + "@" + strconv.FormatInt(slot.Unix(), 10)
+	ok, err := c.locker.TryLock(key, c.initialPeriod)
+	return ok && err == nil
+}
+
+// SetHistory set the store which every execution of the job is appended to.
+func (c *Cron) SetHistory(history HistoryStore) *Cron {
+	c.history = history
+	return c
 }
 
 // returns a new CronJob job runner.
@@ -115,8 +203,7 @@ func NewCron(initialPeriod, stepPeriod, maxPeriod time.Duration) *Cron {
 		stepPeriod:    stepPeriod,
 		maxPeriod:     maxPeriod,
 		job:           nil,
-		wait:          make(chan bool),
-		stop:          make(chan struct{}),
+		clock:         SystemClock,
 		running:       false,
 	}
 	cronObj.schedule(initialPeriod)
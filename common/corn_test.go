@@ -1 +1,207 @@
 package common
+
+import (
+	"testing"
+	"time"
+)
+
+// countJob counts its runs, and declines while decline is true.
+type countJob struct {
+	runs    int
+	decline bool
+	panics  bool
+	done    chan struct{}
+}
+
+func (j *countJob) Run() bool {
+	j.runs++
+	if j.done != nil {
+		j.done <- struct{}{}
+	}
+	if j.panics {
+		panic("boom")
+	}
+	return j.decline
+}
+
+func newTestCron(job Job) (*Cron, *FakeClock) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCron(10*time.Second, 5*time.Second, 30*time.Second).SetClock(clock).Do(job)
+	return c, clock
+}
+
+func TestCron_WaitsForPeriod(t *testing.T) {
+	job := &countJob{}
+	c, clock := newTestCron(job)
+
+	clock.Advance(9 * time.Second)
+	c.runJob()
+	if job.runs != 0 {
+		t.Fatal("job should not run before the period", job.runs)
+	}
+
+	clock.Advance(2 * time.Second)
+	c.runJob()
+	if job.runs != 1 {
+		t.Fatal("job should run after the period", job.runs)
+	}
+
+	c.runJob()
+	if job.runs != 1 {
+		t.Fatal("job should wait for the next period", job.runs)
+	}
+}
+
+func TestCron_DeclineBacksOff(t *testing.T) {
+	job := &countJob{decline: true}
+	c, clock := newTestCron(job)
+
+	// every declined run adds the step period, until the max period
+	for _, want := range []time.Duration{15, 20, 25, 30, 30} {
+		clock.Advance(c.period + time.Second)
+		c.runJob()
+		if c.period != want*time.Second {
+			t.Fatalf("period should be %ds, got %s", want, c.period)
+		}
+	}
+
+	// a run which does not decline resets the initial period
+	job.decline = false
+	clock.Advance(c.period + time.Second)
+	c.runJob()
+	if c.period != 10*time.Second {
+		t.Fatal("period should be reset to the initial period", c.period)
+	}
+	if job.runs != 6 {
+		t.Fatal("job should run 6 times", job.runs)
+	}
+}
+
+func TestCron_RecordsHistory(t *testing.T) {
+	job := &countJob{panics: true}
+	history := &memoryHistory{}
+	c, clock := newTestCron(job)
+	c.SetHistory(history).Name("boom")
+
+	clock.Advance(11 * time.Second)
+	c.runJob()
+	if len(history.records) != 1 {
+		t.Fatal("a run should be recorded", history.records)
+	}
+	r := history.records[0]
+	if r.Job != "boom" || r.Result != RunPanic || r.Error != "boom" || r.NextPeriod != 10*time.Second {
+		t.Fatal("the panic should be recorded", r)
+	}
+}
+
+func TestCron_Locker(t *testing.T) {
+	locker := NewMemoryLocker()
+	a, b := &countJob{}, &countJob{}
+	ca, clock := newTestCron(a)
+	cb := NewCron(10*time.Second, 5*time.Second, 30*time.Second).SetClock(clock).Do(b)
+	ca.Name("job").SetLocker(locker.SetClock(clock))
+	cb.Name("job").SetLocker(locker)
+
+	clock.Advance(11 * time.Second)
+	ca.runJob()
+	cb.runJob()
+	if a.runs+b.runs != 1 {
+		t.Fatal("job should run on exactly one instance", a.runs, b.runs)
+	}
+}
+
+func TestCron_LockerSlots(t *testing.T) {
+	locker := NewMemoryLocker()
+	a, b := &countJob{decline: true}, &countJob{}
+	ca, clock := newTestCron(a)
+	ca.Name("job").SetLocker(locker.SetClock(clock))
+	// started just before the slot boundary, and backing off on the other instance
+	clock.Advance(9500 * time.Millisecond)
+	cb := NewCron(10*time.Second, 5*time.Second, 30*time.Second).SetClock(clock).Do(b).Name("job").SetLocker(locker)
+
+	slots := make(map[int64]int)
+	for i := 0; i < 120; i++ {
+		clock.Advance(500 * time.Millisecond)
+		before := a.runs + b.runs
+		ca.runJob()
+		cb.runJob()
+		slots[clock.Now().Truncate(10*time.Second).Unix()] += a.runs + b.runs - before
+	}
+	for slot, runs := range slots {
+		if runs > 1 {
+			t.Fatal("job should run at most once per slot", slot, runs)
+		}
+	}
+	if a.runs == 0 || b.runs == 0 {
+		t.Fatal("both instances should get slots", a.runs, b.runs)
+	}
+}
+
+func TestCron_StartStop(t *testing.T) {
+	job := &countJob{done: make(chan struct{})}
+	c, clock := newTestCron(job)
+	c.Start()
+	defer c.Stop()
+
+	// the ticker checks the schedule every second
+	for i := 0; i < 11; i++ {
+		clock.Advance(time.Second)
+	}
+	select {
+	case <-job.done:
+	case <-time.After(time.Second):
+		t.Fatal("job should run when the clock is advanced")
+	}
+}
+
+func TestCron_StopRunning(t *testing.T) {
+	release := make(chan struct{})
+	job := &blockingJob{entered: make(chan struct{}, 1), release: release}
+	c, clock := newTestCron(job)
+	c.Start()
+	defer close(release)
+
+	for i := 0; i < 11; i++ {
+		clock.Advance(time.Second)
+	}
+	select {
+	case <-job.entered:
+	case <-time.After(time.Second):
+		t.Fatal("job should run when the clock is advanced")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop should not wait for the running job")
+	}
+}
+
+// blockingJob runs until it is released
+type blockingJob struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (j *blockingJob) Run() bool {
+	j.entered <- struct{}{}
+	<-j.release
+	return false
+}
+
+type memoryHistory struct {
+	records []RunRecord
+}
+
+func (m *memoryHistory) Append(record RunRecord) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryHistory) Query(query HistoryQuery) ([]RunRecord, error) {
+	return m.records, nil
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Entry is a single log record.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Caller  string
+	Message string
+	Stack   []string
+	// key-value pairs
+	Fields []interface{}
+}
+
+// Encoder formats an Entry as a single line, terminated by a newline.
+type Encoder interface {
+	Encode(entry *Entry) []byte
+}
+
+// --- console encoder ---
+// ConsoleEncoder is the human readable format,
+// e.g. "tong says:2006/01/02 15:04:05 main.go:12: INFO hello key=value"
+type ConsoleEncoder struct {
+	Prefix string
+}
+
+func (c *ConsoleEncoder) Encode(entry *Entry) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(c.Prefix)
+	buf.WriteString(entry.Time.Format("2006/01/02 15:04:05 "))
+	if entry.Caller != "" {
+		buf.WriteString(entry.Caller)
+		buf.WriteString(": ")
+	} // if>
+	buf.WriteString(entry.Level.String())
+	buf.WriteString(" ")
+	buf.WriteString(oneLine(entry.Message))
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		writeLogfmt(buf, fmt.Sprint(entry.Fields[i]), entry.Fields[i+1])
+	} // for>
+	if len(entry.Stack) > 0 {
+		writeLogfmt(buf, "stack", strings.Join(entry.Stack, " <- "))
+	} // if>
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// --- logfmt encoder ---
+// LogfmtEncoder is the key=value format, e.g. "time=... level=INFO msg=hello key=value"
+type LogfmtEncoder struct{}
+
+func (LogfmtEncoder) Encode(entry *Entry) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("time=")
+	buf.WriteString(entry.Time.Format(time.RFC3339Nano))
+	writeLogfmt(buf, "level", entry.Level.String())
+	if entry.Caller != "" {
+		writeLogfmt(buf, "caller", entry.Caller)
+	} // if>
+	writeLogfmt(buf, "msg", entry.Message)
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		writeLogfmt(buf, fmt.Sprint(entry.Fields[i]), entry.Fields[i+1])
+	} // for>
+	if len(entry.Stack) > 0 {
+		writeLogfmt(buf, "stack", strings.Join(entry.Stack, " <- "))
+	} // if>
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func writeLogfmt(buf *bytes.Buffer, key string, value interface{}) {
+	buf.WriteByte(' ')
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	v := fmt.Sprint(value)
+	if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+		v = fmt.Sprintf("%q", v)
+	} // if>
+	buf.WriteString(v)
+}
+
+// --- JSON encoder ---
+// JSONEncoder is the JSON object per line format,
+// the fields are merged into the object beside time, level, caller, msg and stack.
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(entry *Entry) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, entry.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, entry.Level.String())
+	if entry.Caller != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSON(buf, entry.Caller)
+	} // if>
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, entry.Message)
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		buf.WriteByte(',')
+		writeJSON(buf, fmt.Sprint(entry.Fields[i]))
+		buf.WriteByte(':')
+		writeJSON(buf, entry.Fields[i+1])
+	} // for>
+	if len(entry.Stack) > 0 {
+		buf.WriteString(`,"stack":`)
+		writeJSON(buf, entry.Stack)
+	} // if>
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// write the value as JSON, or as a string if it cannot be marshaled
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	} // if>
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	} // if>
+	buf.Write(data)
+}
+
+// keep the record in a single line
+func oneLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// --- run result of a job ---
+const (
+	RunSuccess = "success"
+	RunPanic   = "panic"
+)
+
+// RunRecord is the detail of one execution of a Cron job.
+type RunRecord struct {
+	Job        string        `json:"job"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Duration   time.Duration `json:"duration"`
+	Result     string        `json:"result"`
+	Error      string        `json:"error,omitempty"`
+	Declined   bool          `json:"declined"`
+	NextPeriod time.Duration `json:"next_period"`
+}
+
+// HistoryQuery filters the run records, zero fields match everything.
+type HistoryQuery struct {
+	Job   string
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+func (q HistoryQuery) match(r *RunRecord) bool {
+	if q.Job != "" && q.Job != r.Job {
+		return false
+	}
+	if !q.Since.IsZero() && r.Start.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Start.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// HistoryStore keeps the run records of Cron jobs.
+type HistoryStore interface {
+	Append(record RunRecord) error
+	// Query returns the matched records, the newest first.
+	Query(query HistoryQuery) ([]RunRecord, error)
+}
+
+// RetentionPolicy decides how long the run records are kept,
+// zero fields mean no limit.
+type RetentionPolicy struct {
+	MaxAge     time.Duration
+	MaxRecords int
+}
+
+// --- JSON lines file history ---
+// FileHistory is a HistoryStore appending one JSON record per line to a file,
+// the appends and prunes of processes sharing the file are serialized by a lock file aside.
+type FileHistory struct {
+	fileName  string
+	retention RetentionPolicy
+	clock     Clock
+	appended  int
+	lock      sync.Mutex
+}
+
+// NewFileHistory returns a new FileHistory instance.
+func NewFileHistory(fileName string, retention RetentionPolicy) *FileHistory {
+	return &FileHistory{fileName: fileName, retention: retention, clock: SystemClock}
+}
+
+// SetClock set the clock applying the retention policy.
+func (f *FileHistory) SetClock(clock Clock) *FileHistory {
+	f.clock = clock
+	return f
+}
+
+func (f *FileHistory) Append(record RunRecord) (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	} // if>
+	// no other process appends while the file is pruned and renamed
+	unlock, err := lockFile(f.fileName + ".lock")
+	if err != nil {
+		return err
+	} // if>
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+
+	file, err := os.OpenFile(f.fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	} // if>
+	_, err = file.Write(append(data, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	} // if>
+	if err != nil {
+		return err
+	} // if>
+
+	// apply the retention policy every once in a while
+	const pruneEvery = 64
+	f.appended++
+	if f.appended%pruneEvery == 0 {
+		return f.prune()
+	} // if>
+	return nil
+}
+
+func (f *FileHistory) Query(query HistoryQuery) ([]RunRecord, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	records, err := f.load()
+	if err != nil {
+		return nil, err
+	} // if>
+	ret := make([]RunRecord, 0)
+	for i := len(records) - 1; i >= 0; i-- {
+		if query.Limit > 0 && len(ret) == query.Limit {
+			break
+		} // if>>
+		if query.match(&records[i]) {
+			ret = append(ret, records[i])
+		} // if>>
+	} // for>
+	return ret, nil
+}
+
+// Prune removes the records out of the retention policy.
+func (f *FileHistory) Prune() (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	unlock, err := lockFile(f.fileName + ".lock")
+	if err != nil {
+		return err
+	} // if>
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return f.prune()
+}
+
+func (f *FileHistory) load() ([]RunRecord, error) {
+	file, err := os.Open(f.fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} // if>
+	if err != nil {
+		return nil, err
+	} // if>
+	defer file.Close()
+
+	records := make([]RunRecord, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r RunRecord
+		// skip the broken line, e.g. written by a crashed process
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		} // if>>
+	} // for>
+	return records, scanner.Err()
+}
+
+func (f *FileHistory) prune() error {
+	records, err := f.load()
+	if err != nil {
+		return err
+	} // if>
+
+	if f.retention.MaxAge > 0 {
+		deadline := f.clock.Now().Add(-f.retention.MaxAge)
+		for len(records) > 0 && records[0].Start.Before(deadline) {
+			records = records[1:]
+		} // for>>
+	} // if>
+	if f.retention.MaxRecords > 0 && len(records) > f.retention.MaxRecords {
+		records = records[len(records)-f.retention.MaxRecords:]
+	} // if>
+
+	data := make([]byte, 0)
+	for i := range records {
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		} // if>>
+		data = append(append(data, line...), '\n')
+	} // for>
+
+	// write aside and rename, so a crash never loses the whole history
+	tmp := f.fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	} // if>
+	return os.Rename(tmp, f.fileName)
+}
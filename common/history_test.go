@@ -0,0 +1,74 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tong-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := NewFileHistory(filepath.Join(dir, "cron.jsonl"), RetentionPolicy{MaxRecords: 3})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		job := "a"
+		if i%2 == 1 {
+			job = "b"
+		}
+		record := RunRecord{Job: job, Start: start.Add(time.Duration(i) * time.Second), Result: RunSuccess}
+		if err := h.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, _ := h.Query(HistoryQuery{Job: "a"})
+	if len(records) != 3 || !records[0].Start.After(records[1].Start) {
+		t.Fatal("query should return the records of job a, the newest first", records)
+	}
+
+	if err := h.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = h.Query(HistoryQuery{})
+	if len(records) != 3 {
+		t.Fatal("prune should keep 3 records", records)
+	}
+	records, _ = h.Query(HistoryQuery{Limit: 1})
+	if len(records) != 1 || records[0].Job != "a" {
+		t.Fatal("limit should keep the newest record", records)
+	}
+}
+
+func TestFileHistory_Processes(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "cron.jsonl")
+	// the instances stand for processes sharing the file
+	const writers, appends = 4, 200
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		h := NewFileHistory(fileName, RetentionPolicy{MaxRecords: writers * appends})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < appends; j++ {
+				if err := h.Append(RunRecord{Job: "a", Start: time.Now(), Result: RunSuccess}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, _ := NewFileHistory(fileName, RetentionPolicy{}).Query(HistoryQuery{})
+	if len(records) != writers*appends {
+		t.Fatal("no record should be lost by the prunes", len(records))
+	}
+}
@@ -5,6 +5,8 @@ const (
 	charsetUTF8                    = "charset=UTF-8"
 	MIMEApplicationJSON            = "application/json"
 	MIMEApplicationJSONCharsetUTF8 = MIMEApplicationJSON + "; " + charsetUTF8
+	MIMEApplicationXML             = "application/xml"
+	MIMEApplicationXMLCharsetUTF8  = MIMEApplicationXML + "; " + charsetUTF8
 	MIMETextXML                    = "text/xml"
 	MIMETextXMLCharsetUTF8         = MIMETextXML + "; " + charsetUTF8
 	MIMEApplicationForm            = "application/x-www-form-urlencoded"
@@ -21,16 +23,19 @@ const (
 const (
 	HeaderAccept              = "Accept"
 	HeaderAcceptEncoding      = "Accept-Encoding"
+	HeaderAcceptLanguage      = "Accept-Language"
 	HeaderAllow               = "Allow"
 	HeaderAuthorization       = "Authorization"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
+	HeaderContentLanguage     = "Content-Language"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
 	HeaderIfModifiedSince     = "If-Modified-Since"
 	HeaderLastModified        = "Last-Modified"
+	HeaderLink                = "Link"
 	HeaderLocation            = "Location"
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
@@ -46,4 +51,12 @@ const (
 	HeaderXRequestedWith      = "X-Requested-With"
 	HeaderServer              = "Server"
 	HeaderOrigin              = "Origin"
+
+	// Access control
+	HeaderAccessControlRequestMethod  = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin    = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods   = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders   = "Access-Control-Allow-Headers"
+	HeaderAccessControlMaxAge         = "Access-Control-Max-Age"
 )
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Locker decides which instance owns a job on a schedule tick,
+// it is consulted by Cron before each run.
+type Locker interface {
+	// TryLock returns true if the caller now owns the key,
+	// the ownership is released automatically after ttl.
+	TryLock(key string, ttl time.Duration) (bool, error)
+}
+
+// --- memory locker ---
+// MemoryLocker is a Locker inside a single process, it is useful for tests.
+type MemoryLocker struct {
+	keys  map[string]time.Time
+	clock Clock
+	lock  sync.Mutex
+}
+
+// NewMemoryLocker returns a new MemoryLocker instance.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{keys: make(map[string]time.Time), clock: SystemClock}
+}
+
+// SetClock set the clock measuring the ttl of keys.
+func (m *MemoryLocker) SetClock(clock Clock) *MemoryLocker {
+	m.clock = clock
+	return m
+}
+
+func (m *MemoryLocker) TryLock(key string, ttl time.Duration) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.clock.Now()
+	for k, expire := range m.keys {
+		if now.After(expire) {
+			delete(m.keys, k)
+		} // if>>
+	} // for>
+
+	if _, exists := m.keys[key]; exists {
+		return false, nil
+	} // if>
+	m.keys[key] = now.Add(ttl)
+	return true, nil
+}
+
+// --- file locker ---
+// FileLocker is a Locker shared by processes on a single host,
+// every key is a lock file in dir, whose modify time is the expire time.
+type FileLocker struct {
+	dir   string
+	clock Clock
+}
+
+// NewFileLocker returns a new FileLocker instance keeping lock files in dir.
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{dir: dir, clock: SystemClock}
+}
+
+// SetClock set the clock measuring the ttl of keys.
+func (f *FileLocker) SetClock(clock Clock) *FileLocker {
+	f.clock = clock
+	return f
+}
+
+func (f *FileLocker) TryLock(key string, ttl time.Duration) (bool, error) {
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return false, err
+	} // if>
+	f.sweep()
+
+	// prepare the lock file aside, then link it into place,
+	// so no other process sees a lock without its expire time
+	tmp, err := ioutil.TempFile(f.dir, "tmp-")
+	if err != nil {
+		return false, err
+	} // if>
+	_ = tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	expire := f.clock.Now().Add(ttl)
+	if err := os.Chtimes(tmp.Name(), expire, expire); err != nil {
+		return false, err
+	} // if>
+
+	err = os.Link(tmp.Name(), filepath.Join(f.dir, lockFileName(key)))
+	if os.IsExist(err) {
+		return false, nil
+	} // if>
+	return err == nil, err
+}
+
+// remove the expired lock files
+func (f *FileLocker) sweep() {
+	matches, _ := filepath.Glob(filepath.Join(f.dir, "*.lock"))
+	now := f.clock.Now()
+	for _, name := range matches {
+		info, err := os.Stat(name)
+		if err == nil && now.After(info.ModTime()) {
+			_ = os.Remove(name)
+		} // if>>
+	} // for>
+}
+
+// keep letters and digits only, so every key is a valid file name
+func lockFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, key) + ".lock"
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemoryLocker_TryLock(t *testing.T) {
+	l := NewMemoryLocker()
+	if ok, _ := l.TryLock("job@1", time.Minute); !ok {
+		t.Fatal("first lock should succeed")
+	}
+	if ok, _ := l.TryLock("job@1", time.Minute); ok {
+		t.Fatal("second lock of the same tick should fail")
+	}
+	if ok, _ := l.TryLock("job@2", time.Minute); !ok {
+		t.Fatal("lock of the next tick should succeed")
+	}
+}
+
+func TestFileLocker_TryLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tong-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := NewFileLocker(dir), NewFileLocker(dir)
+	if ok, err := a.TryLock("main.job@1", time.Minute); !ok || err != nil {
+		t.Fatal("first lock should succeed", err)
+	}
+	if ok, _ := b.TryLock("main.job@1", time.Minute); ok {
+		t.Fatal("other instance should not get the same tick")
+	}
+
+	// an expired lock is released
+	if ok, _ := a.TryLock("main.job@2", -time.Second); !ok {
+		t.Fatal("lock should succeed")
+	}
+	if ok, _ := b.TryLock("main.job@2", time.Minute); !ok {
+		t.Fatal("expired lock should be released")
+	}
+}
@@ -1,36 +1,109 @@
 package common
 
 import (
+	"context"
 	"fmt"
-	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// $--- level ---
+// Level is the severity of a log message.
+type Level int32
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelFatal {
+		return fmt.Sprintf("LEVEL(%d)", l)
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the Level of its name, case insensitive.
+func ParseLevel(name string) (Level, error) {
+	for i, v := range levelNames {
+		if strings.EqualFold(name, v) {
+			return Level(i), nil
+		} // if>>
+	} // for>
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// $--- logger ---
+// Logger is shared by all the loggers derived from it with With,
+// they have their own fields but share the level and the output.
 type Logger struct {
-	stdLogger        *log.Logger
-	debug            bool
+	core             *logCore
+	fields           []interface{}
 	callers          []string
 	errorCallerDepth uint8
 }
 
+type logCore struct {
+	sinks      []Sink
+	async      *asyncWriter
+	backend    slog.Handler
+	encoder    Encoder
+	level      int32
+	pkgLevels  map[string]Level
+	samplers   map[Level]*sampler
+	dedupers   map[Level]*deduper
+	redactor   *Redactor
+	clock      Clock
+	lock       sync.RWMutex
+	writeLock  sync.Mutex
+	filterLock sync.Mutex
+}
+
+// NewDefaultLogger returns a logger writing to stdout only,
+// a file is written by NewLogger or a FileSink.
 func NewDefaultLogger(debug bool) *Logger {
-	return NewLogger("tong.log", 4, 1, "tong says:", debug)
+	return NewLoggerWithSinks("tong says:", debug, StdoutSink(LevelTrace))
 }
 
 func NewLogger(fileName string, fileMaxSize int, fileMaxExpire int, prefix string, debug bool) *Logger {
-	lj := &lumberjack.Logger{
+	file := FileSink(FileOptions{
 		Filename:   fileName,
 		MaxSize:    fileMaxSize,
 		MaxAge:     fileMaxExpire,
 		MaxBackups: 1,
-	}
-	stdLogger := log.New(io.MultiWriter(os.Stdout, lj), prefix, log.Ldate|log.Ltime|log.Lshortfile)
+	}, LevelTrace)
+	return NewLoggerWithSinks(prefix, debug, StdoutSink(LevelTrace), file)
+}
+
+// NewLoggerWithSinks returns a logger writing the records to the sinks.
+func NewLoggerWithSinks(prefix string, debug bool, sinks ...Sink) *Logger {
+	level := LevelInfo
+	if debug {
+		level = LevelDebug
+	} // if>
 	return &Logger{
-		stdLogger:        stdLogger,
-		debug:            debug,
+		core: &logCore{
+			sinks:     sinks,
+			encoder:   &ConsoleEncoder{Prefix: prefix},
+			level:     int32(level),
+			pkgLevels: make(map[string]Level),
+			samplers:  make(map[Level]*sampler),
+			dedupers:  make(map[Level]*deduper),
+			clock:     SystemClock,
+		},
 		errorCallerDepth: 3,
 	}
 }
@@ -51,16 +124,18 @@ func (l *Logger) withCaller(skipLevel int) *Logger {
 	return cp
 }
 
-func (l *Logger) withCallersFrames() *Logger {
-	minDepth, maxDepth := 1, l.errorCallerDepth
-	callers := make([]string, 0, maxDepth)
-	pcs := make([]uintptr, maxDepth)
+// withCallersFrames keeps the caller stack starting at skip
+func (l *Logger) withCallersFrames(skip int) *Logger {
+	callers := make([]string, 0, l.errorCallerDepth)
+	pcs := make([]uintptr, l.errorCallerDepth)
 
-	depth := runtime.Callers(minDepth, pcs)
+	depth := runtime.Callers(skip+2, pcs)
 	frames := runtime.CallersFrames(pcs[:depth])
-	for frame, more := frames.Next(); more; frame, more = frames.Next() {
-		callerInfo := fmt.Sprintf("%s:%d:%s", frame.File, frame.Line, frame.Function)
-		callers = append(callers, callerInfo)
+	for {
+		frame, more := frames.Next()
+		if frame.PC != 0 {
+			callers = append(callers, fmt.Sprintf("%s:%d:%s", frame.File, frame.Line, frame.Function))
+		} //>>
 		if !more {
 			break
 		} //>>
@@ -71,36 +146,288 @@ func (l *Logger) withCallersFrames() *Logger {
 	return cp
 }
 
+// With returns a child logger, which adds the key-value pairs to every message.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	cp := l.copy()
+	cp.fields = make([]interface{}, 0, len(l.fields)+len(fields))
+	cp.fields = append(cp.fields, l.fields...)
+	cp.fields = append(cp.fields, fields...)
+	if len(cp.fields)%2 == 1 {
+		cp.fields = append(cp.fields, "!MISSING")
+	} // if>
+	return cp
+}
+
 func (l *Logger) SetCallerDepth(depth uint8) {
 	l.errorCallerDepth = depth
 }
 
+// SetLevel changes the minimum level at runtime, for all the derived loggers.
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.core.level, int32(level))
+}
+
+// Level returns the minimum level.
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.core.level))
+}
+
+// SetClock set the clock timing the records, for all the derived loggers.
+func (l *Logger) SetClock(clock Clock) {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+	l.core.clock = clock
+}
+
+// Clock returns the clock timing the records.
+func (l *Logger) Clock() Clock {
+	return l.core.currentClock()
+}
+
+func (c *logCore) currentClock() Clock {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.clock
+}
+
+// SetPackageLevel overrides the minimum level for the messages
+// logged from the package and its sub packages, e.g. "github.com/ming3000/tong".
+func (l *Logger) SetPackageLevel(pkg string, level Level) {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+	l.core.pkgLevels[pkg] = level
+}
+
+// ResetPackageLevel removes the override of the package.
+func (l *Logger) ResetPackageLevel(pkg string) {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+	delete(l.core.pkgLevels, pkg)
+}
+
+// enabled reports if the level is enabled for the caller at skip
+func (l *Logger) enabled(level Level, skip int) bool {
+	l.core.lock.RLock()
+	overridden := len(l.core.pkgLevels) > 0
+	l.core.lock.RUnlock()
+
+	if !overridden {
+		return level >= l.Level()
+	} // if>
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return level >= l.Level()
+	} // if>
+	return level >= l.packageLevel(funcPackage(runtime.FuncForPC(pc).Name()))
+}
+
+// packageLevel returns the minimum level of the package,
+// the longest matched override wins
+func (l *Logger) packageLevel(pkg string) Level {
+	l.core.lock.RLock()
+	defer l.core.lock.RUnlock()
+
+	match, min := "", l.Level()
+	for p, v := range l.core.pkgLevels {
+		if len(p) > len(match) && (pkg == p || strings.HasPrefix(pkg, p+"/")) {
+			match, min = p, v
+		} // if>>
+	} // for>
+	return min
+}
+
+// funcPackage returns the package path of a full function name,
+// e.g. "github.com/ming3000/tong.(*Tong).ServeHTTP" is in "github.com/ming3000/tong"
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// SetEncoder changes the format of the records, for all the derived loggers.
+func (l *Logger) SetEncoder(encoder Encoder) {
+	l.core.writeLock.Lock()
+	defer l.core.writeLock.Unlock()
+	l.core.encoder = encoder
+}
+
+// SetSinks changes the destinations of the records, for all the derived loggers.
+func (l *Logger) SetSinks(sinks ...Sink) {
+	l.core.writeLock.Lock()
+	defer l.core.writeLock.Unlock()
+	l.core.sinks = sinks
+}
+
+// SetOutput writes all the records to the writer only.
+func (l *Logger) SetOutput(writer io.Writer) {
+	l.SetSinks(NewSink(writer, LevelTrace))
+}
+
+// output writes the message at level as a single record, the caller is at skip
+func (l *Logger) output(level Level, skip int, message string, withStack bool) {
+	entry := &Entry{
+		Time:    l.Clock().Now(),
+		Level:   level,
+		Message: message,
+		Fields:  l.fields,
+	}
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		entry.Caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+	} // if>
+	if withStack {
+		entry.Stack = l.withCallersFrames(skip).callers
+	} // if>
+
+	l.core.emit(entry)
+}
+
+// emit filters the entry, then sends the entries in place of it
+func (c *logCore) emit(entry *Entry) {
+	c.send(c.filter(entry))
+}
+
+// send hands the entries to the slog backend, or encodes them for the sinks
+func (c *logCore) send(entries []*Entry) {
+	for _, e := range entries {
+		c.writeLock.Lock()
+		backend := c.backend
+		record := []byte(nil)
+		if backend == nil {
+			record = c.encoder.Encode(e)
+		} // if>>
+		async := c.async
+		c.writeLock.Unlock()
+
+		if backend != nil {
+			_ = backend.Handle(context.Background(), toSlogRecord(e))
+		} else if async != nil {
+			async.push(e.Level, record)
+		} else {
+			c.write(e.Level, record)
+		} // else>>
+	} // for>
+}
+
+// write the record to the sinks, a failed sink never stops the others
+func (c *logCore) write(level Level, record []byte) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	for _, sink := range c.sinks {
+		if level >= sink.Level() {
+			_ = sink.Write(level, record)
+		} // if>>
+	} // for>
+}
+
+// sync flushes the sinks which buffer the records
+func (c *logCore) sync() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	var err error
+	for _, sink := range c.sinks {
+		if syncer, ok := sink.(Syncer); ok {
+			if e := syncer.Sync(); e != nil && err == nil {
+				err = e
+			} // if>>>
+		} // if>>
+	} // for>
+	return err
+}
+
+// Sync writes out the repeat counts and the buffered records, it should be called before exiting.
+func (l *Logger) Sync() error {
+	l.core.flushDedupers(nil)
+	l.core.writeLock.Lock()
+	async := l.core.async
+	l.core.writeLock.Unlock()
+
+	if async != nil {
+		async.drain()
+	} // if>
+	return l.core.sync()
+}
+
+// $--- print ---
+func (l *Logger) TraceFormat(format string, message ...interface{}) {
+	if l.enabled(LevelTrace, 1) {
+		l.output(LevelTrace, 2, fmt.Sprintf(format, message...), false)
+	} // if>
+}
+
+func (l *Logger) Trace(message ...interface{}) {
+	if l.enabled(LevelTrace, 1) {
+		l.output(LevelTrace, 2, sprintln(message...), false)
+	} // if>
+}
+
 func (l *Logger) DebugFormat(format string, message ...interface{}) {
-	if l.debug {
-		l.stdLogger.Println(fmt.Sprintf(format, message...))
+	if l.enabled(LevelDebug, 1) {
+		l.output(LevelDebug, 2, fmt.Sprintf(format, message...), false)
 	} // if>
 }
 
 func (l *Logger) Debug(message ...interface{}) {
-	if l.debug {
-		l.stdLogger.Println(message...)
+	if l.enabled(LevelDebug, 1) {
+		l.output(LevelDebug, 2, sprintln(message...), false)
+	} // if>
+}
+
+func (l *Logger) InfoFormat(format string, message ...interface{}) {
+	if l.enabled(LevelInfo, 1) {
+		l.output(LevelInfo, 2, fmt.Sprintf(format, message...), false)
+	} // if>
+}
+
+func (l *Logger) Info(message ...interface{}) {
+	if l.enabled(LevelInfo, 1) {
+		l.output(LevelInfo, 2, sprintln(message...), false)
+	} // if>
+}
+
+func (l *Logger) WarnFormat(format string, message ...interface{}) {
+	if l.enabled(LevelWarn, 1) {
+		l.output(LevelWarn, 2, fmt.Sprintf(format, message...), false)
+	} // if>
+}
+
+func (l *Logger) Warn(message ...interface{}) {
+	if l.enabled(LevelWarn, 1) {
+		l.output(LevelWarn, 2, sprintln(message...), false)
 	} // if>
 }
 
 func (l *Logger) ErrorFormat(format string, message ...interface{}) {
-	l.stdLogger.Println("Error:")
-	ll := l.withCallersFrames()
-	for _, c := range ll.callers {
-		ll.stdLogger.Println(c)
-	} // for>
-	ll.stdLogger.Println(fmt.Sprintf(format, message...))
+	if l.enabled(LevelError, 1) {
+		l.output(LevelError, 2, fmt.Sprintf(format, message...), true)
+	} // if>
 }
 
+// Error prints the format and the message like Println,
+// the format is kept in the parameters for compatibility.
 func (l *Logger) Error(format string, message ...interface{}) {
-	l.stdLogger.Println("Error:")
-	ll := l.withCallersFrames()
-	for _, c := range ll.callers {
-		ll.stdLogger.Println(c)
-	} // for>
-	ll.stdLogger.Println(message...)
+	if l.enabled(LevelError, 1) {
+		l.output(LevelError, 2, sprintln(append([]interface{}{format}, message...)...), true)
+	} // if>
+}
+
+// FatalFormat prints the message like ErrorFormat, then exits the process.
+func (l *Logger) FatalFormat(format string, message ...interface{}) {
+	l.output(LevelFatal, 2, fmt.Sprintf(format, message...), true)
+	_ = l.Sync()
+	os.Exit(1)
+}
+
+// Fatal prints the message like Error, then exits the process.
+func (l *Logger) Fatal(message ...interface{}) {
+	l.output(LevelFatal, 2, sprintln(message...), true)
+	_ = l.Sync()
+	os.Exit(1)
+}
+
+// like fmt.Sprintln without the trailing newline
+func sprintln(message ...interface{}) string {
+	s := fmt.Sprintln(message...)
+	return s[:len(s)-1]
 }
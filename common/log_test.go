@@ -1,6 +1,13 @@
 package common
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -19,6 +26,314 @@ func thirdLevel(l *Logger) {
 }
 
 func TestLogger_Debug(t *testing.T) {
-	l := NewDefaultLogger()
+	l := NewDefaultLogger(true)
 	firstlevel(l)
+	// the default logger writes no file
+	if _, err := os.Stat("tong.log"); !os.IsNotExist(err) {
+		t.Fatal("default logger should write to stdout only", err)
+	}
+}
+
+func newBufferLogger(buf *bytes.Buffer, level Level) *Logger {
+	l := NewLoggerWithSinks("", false)
+	l.SetOutput(buf)
+	l.SetLevel(level)
+	return l
+}
+
+func TestLogger_Level(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.Debug("hidden")
+	l.Info("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "INFO shown") {
+		t.Fatal("debug should be filtered at info level", buf.String())
+	}
+
+	buf.Reset()
+	l.SetLevel(LevelTrace)
+	l.TraceFormat("trace %d", 1)
+	if !strings.Contains(buf.String(), "TRACE trace 1") {
+		t.Fatal("level should change at runtime", buf.String())
+	}
+}
+
+func TestLogger_With(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	child := l.With("request_id", "abc").With("user", "tom cat")
+	child.Warn("login")
+	if !strings.Contains(buf.String(), `WARN login request_id=abc user="tom cat"`) {
+		t.Fatal("fields should be appended", buf.String())
+	}
+
+	// the level is shared by the derived loggers
+	buf.Reset()
+	l.SetLevel(LevelError)
+	child.Info("hidden")
+	if buf.Len() != 0 {
+		t.Fatal("child should follow the level of its parent", buf.String())
+	}
+}
+
+func TestLogger_PackageLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetPackageLevel("github.com/ming3000/tong/common", LevelDebug)
+	l.Debug("shown")
+	if !strings.Contains(buf.String(), "DEBUG shown") {
+		t.Fatal("package override should enable debug", buf.String())
+	}
+
+	buf.Reset()
+	l.ResetPackageLevel("github.com/ming3000/tong/common")
+	l.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Fatal("override should be removed", buf.String())
+	}
+}
+
+func TestLogger_Error(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.Error("haha", "xixi")
+	if !strings.Contains(buf.String(), "ERROR haha xixi") {
+		t.Fatal("format should be printed", buf.String())
+	}
+}
+
+func TestLogger_Encoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetEncoder(JSONEncoder{})
+	l.With("user", 7).ErrorFormat("failed:\n%s", "boom")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatal("a record should be a single line", buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "ERROR" || record["msg"] != "failed:\nboom" || record["user"] != float64(7) {
+		t.Fatal("unexpected record", record)
+	}
+	if caller, _ := record["caller"].(string); !strings.HasPrefix(caller, "log_test.go:") {
+		t.Fatal("caller should be the test", record["caller"])
+	}
+	if stack, _ := record["stack"].([]interface{}); len(stack) == 0 {
+		t.Fatal("error should carry the stack", record)
+	}
+
+	// the time of the record follows the clock
+	buf.Reset()
+	l.SetClock(NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	l.Info("hello")
+	if !strings.Contains(buf.String(), "2020-01-02T03:04:05") {
+		t.Fatal("time should follow the clock", buf.String())
+	}
+	l.SetClock(SystemClock)
+
+	buf.Reset()
+	l.SetEncoder(LogfmtEncoder{})
+	l.With("user", "tom cat").Info("hello")
+	if !strings.Contains(buf.String(), ` level=INFO caller=log_test.go:`) ||
+		!strings.HasSuffix(buf.String(), ` msg=hello user="tom cat"`+"\n") {
+		t.Fatal("unexpected logfmt record", buf.String())
+	}
+}
+
+func TestLogger_Sinks(t *testing.T) {
+	all, errors := new(bytes.Buffer), new(bytes.Buffer)
+	l := NewLoggerWithSinks("", true, NewSink(all, LevelDebug), NewSink(errors, LevelError))
+	l.Debug("debug")
+	l.Error("error")
+	if !strings.Contains(all.String(), "DEBUG debug") || !strings.Contains(all.String(), "ERROR error") {
+		t.Fatal("sink should get all the records", all.String())
+	}
+	if strings.Contains(errors.String(), "debug") || !strings.Contains(errors.String(), "ERROR error") {
+		t.Fatal("sink should get the errors only", errors.String())
+	}
+}
+
+// blockingWriter blocks the writes until it is released
+type blockingWriter struct {
+	bytes.Buffer
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return b.Buffer.Write(p)
+}
+
+// syncBuffer is a buffer written by the background goroutines
+type syncBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestLogger_Async(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	l := newBufferLogger(new(bytes.Buffer), LevelInfo)
+	l.SetOutput(w)
+	l.EnableAsync(AsyncOptions{BufferSize: 2, Policy: OverflowDropNewest})
+
+	// the first record is taken by the writer, then the buffer is full
+	for i := 0; i < 10; i++ {
+		l.InfoFormat("record %d", i)
+	}
+	if l.Dropped() == 0 {
+		t.Fatal("records should be dropped when the buffer is full")
+	}
+	close(w.release)
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), "record 0") {
+		t.Fatal("buffered records should be written on sync", w.String())
+	}
+	if n := uint64(strings.Count(w.String(), "\n")) + l.Dropped(); n != 10 {
+		t.Fatal("every record should be written or dropped", n)
+	}
+}
+
+// syncSink counts the syncs
+type syncSink struct {
+	Sink
+	syncs int32
+}
+
+func (s *syncSink) Sync() error {
+	atomic.AddInt32(&s.syncs, 1)
+	return nil
+}
+
+func TestLogger_AsyncClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	buf := new(syncBuffer)
+	sink := &syncSink{Sink: NewSink(buf, LevelTrace)}
+	l := NewLoggerWithSinks("", false, sink)
+	l.EnableAsync(AsyncOptions{FlushInterval: 10 * time.Millisecond})
+
+	// no sync while idle
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&sink.syncs); n != 0 {
+		t.Fatal("idle sinks should not be synced", n)
+	}
+	l.Info("queued")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "queued") {
+		t.Fatal("buffered records should be written on close", buf.String())
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatal("the async goroutines should stop on close", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// written synchronously after close
+	l.Info("after close")
+	if !strings.Contains(buf.String(), "after close") {
+		t.Fatal("records should be written after close", buf.String())
+	}
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetSampling(LevelInfo, SamplingOptions{Tick: time.Hour, First: 2, Thereafter: 3})
+	for i := 0; i < 10; i++ {
+		l.Info("flood")
+	}
+	l.Warn("other level")
+	// the 1st, 2nd, 5th and 8th are logged
+	if n := strings.Count(buf.String(), "INFO flood"); n != 4 {
+		t.Fatal("unexpected sampled records", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "WARN other level") {
+		t.Fatal("other levels should not be sampled", buf.String())
+	}
+}
+
+func TestLogger_Dedupe(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetDedupe(LevelError, time.Hour)
+	for i := 0; i < 5; i++ {
+		l.Error("disk full")
+	}
+	l.Error("disk ok")
+	if n := strings.Count(buf.String(), "ERROR disk full"); n != 1 {
+		t.Fatal("repeated records should be suppressed", buf.String())
+	}
+	if !strings.Contains(buf.String(), "ERROR last message repeated 4 times: disk full") ||
+		!strings.Contains(buf.String(), "ERROR disk ok") {
+		t.Fatal("repeat count should be logged when the message changes", buf.String())
+	}
+
+	// the repeat count at the end of a flood is logged on sync
+	buf.Reset()
+	l.Error("disk ok")
+	l.Error("disk ok")
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ERROR last message repeated 2 times: disk ok") {
+		t.Fatal("repeat count should be logged on sync", buf.String())
+	}
+}
+
+func TestLogger_DedupeWindow(t *testing.T) {
+	buf := new(syncBuffer)
+	l := NewLoggerWithSinks("", false, NewSink(buf, LevelTrace))
+	l.SetDedupe(LevelError, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		l.Error("disk full")
+	}
+	// logged when the window ends, without another record
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "ERROR last message repeated 2 times: disk full") {
+		if time.Now().After(deadline) {
+			t.Fatal("repeat count should be logged when the window ends", buf.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLogger_DedupeClock(t *testing.T) {
+	buf := new(syncBuffer)
+	l := NewLoggerWithSinks("", false, NewSink(buf, LevelTrace))
+	clock := NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	l.SetClock(clock)
+	l.SetDedupe(LevelError, time.Minute)
+	for i := 0; i < 3; i++ {
+		l.Error("disk full")
+	}
+	// the window is timed by the clock of the logger
+	clock.Advance(59 * time.Second)
+	if strings.Contains(buf.String(), "repeated") {
+		t.Fatal("repeat count is logged before the window ends", buf.String())
+	}
+	clock.Advance(time.Second)
+	if !strings.Contains(buf.String(), "ERROR last message repeated 2 times: disk full") {
+		t.Fatal("repeat count should be logged when the window ends", buf.String())
+	}
 }
@@ -0,0 +1,189 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// --- well known sensitive patterns ---
+var (
+	// 13 to 19 digits, optionally grouped by spaces or dashes
+	CardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// mainland China mobile numbers, and international ones with a plus sign
+	PhoneNumberPattern = regexp.MustCompile(`(?:\+\d{1,3}[ -]?)?\b1[3-9]\d{9}\b|\+\d{1,3}[ -]?\d{6,14}\b`)
+)
+
+// Redactor masks the sensitive data before it is logged.
+type Redactor struct {
+	Mask     string
+	fields   map[string]bool
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor returns a Redactor masking the Authorization, Cookie and Set-Cookie headers.
+func NewRedactor() *Redactor {
+	r := &Redactor{
+		Mask:    "******",
+		fields:  make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+	return r.Headers(HeaderAuthorization, HeaderCookie, HeaderSetCookie)
+}
+
+// Fields masks the values of the fields, the names are case insensitive.
+func (r *Redactor) Fields(names ...string) *Redactor {
+	for _, name := range names {
+		r.fields[strings.ToLower(name)] = true
+	}
+	return r
+}
+
+// Headers masks the values of the headers, the names are case insensitive.
+func (r *Redactor) Headers(names ...string) *Redactor {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	return r
+}
+
+// Patterns masks the matched parts of messages and values.
+func (r *Redactor) Patterns(patterns ...*regexp.Regexp) *Redactor {
+	r.patterns = append(r.patterns, patterns...)
+	return r
+}
+
+// String returns s with the matched patterns masked.
+func (r *Redactor) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, r.Mask)
+	}
+	return s
+}
+
+// the nesting depth of the values redacted, the deeper ones are printed as strings
+const maxRedactDepth = 8
+
+// Field returns the value of the field masked,
+// a field named like a masked header is masked too,
+// and a grouped field like "req.password" is matched by its last name.
+// The maps, structs and slices are copied with their fields masked,
+// the struct fields are named by their json tags.
+func (r *Redactor) Field(key string, value interface{}) interface{} {
+	return r.field(key, value, 0)
+}
+
+func (r *Redactor) field(key string, value interface{}, depth int) interface{} {
+	key = key[strings.LastIndex(key, ".")+1:]
+	if r.fields[strings.ToLower(key)] || r.headers[http.CanonicalHeaderKey(key)] {
+		return r.Mask
+	} // if>
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case http.Header:
+		return r.Header(v)
+	case string:
+		return r.String(v)
+	case error, fmt.Stringer:
+		// printed as they are
+	default:
+		if nested := r.nested(key, reflect.ValueOf(value), depth); nested != nil {
+			return nested
+		} // if>>
+	}
+	s := fmt.Sprint(value)
+	if masked := r.String(s); masked != s {
+		return masked
+	} // if>
+	return value
+}
+
+// nested returns a copy of the map, the struct or the slice with the fields masked,
+// or nil for the other values
+func (r *Redactor) nested(key string, v reflect.Value, depth int) interface{} {
+	if depth >= maxRedactDepth {
+		return nil
+	} // if>
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		} // if>>
+		v = v.Elem()
+	} // for>
+
+	switch v.Kind() {
+	case reflect.Map:
+		ret := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			ret[name] = r.field(name, iter.Value().Interface(), depth+1)
+		} // for>>
+		return ret
+	case reflect.Struct:
+		ret := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			} // if>>>
+			name := f.Name
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			} // else>>>
+			ret[name] = r.field(name, v.Field(i).Interface(), depth+1)
+		} // for>>
+		return ret
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		} // if>>
+		ret := make([]interface{}, v.Len())
+		for i := range ret {
+			ret[i] = r.field(key, v.Index(i).Interface(), depth+1)
+		} // for>>
+		return ret
+	}
+	return nil
+}
+
+// Header returns a copy of the header with the values masked.
+func (r *Redactor) Header(header http.Header) http.Header {
+	ret := make(http.Header, len(header))
+	for k, values := range header {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			if r.headers[http.CanonicalHeaderKey(k)] {
+				masked[i] = r.Mask
+			} else {
+				masked[i] = r.String(v)
+			} // else>>>
+		} // for>>
+		ret[k] = masked
+	} // for>
+	return ret
+}
+
+// redact the message and the fields of the entry
+func (r *Redactor) entry(entry *Entry) {
+	entry.Message = r.String(entry.Message)
+	fields := make([]interface{}, len(entry.Fields))
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		fields[i] = entry.Fields[i]
+		fields[i+1] = r.Field(fmt.Sprint(entry.Fields[i]), entry.Fields[i+1])
+	}
+	entry.Fields = fields
+}
+
+// SetRedactor masks the sensitive data of every record, for all the derived loggers.
+func (l *Logger) SetRedactor(redactor *Redactor) {
+	l.core.filterLock.Lock()
+	defer l.core.filterLock.Unlock()
+	l.core.redactor = redactor
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_SecretsNeverReachSinks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	for _, encoder := range []Encoder{&ConsoleEncoder{}, JSONEncoder{}, LogfmtEncoder{}} {
+		buf.Reset()
+		l := newBufferLogger(buf, LevelInfo)
+		l.SetEncoder(encoder)
+		l.SetRedactor(NewRedactor().Fields("password").Patterns(CardNumberPattern, PhoneNumberPattern))
+
+		header := http.Header{}
+		header.Set("Authorization", "Bearer s3cr3t-token")
+		header.Set("Cookie", "session=s3cr3t-session")
+		header.Set("Accept", "text/plain")
+		l.With("password", "s3cr3t-password", "headers", header, "cookie", "s3cr3t-cookie").
+			InfoFormat("paid with card 4111 1111 1111 1111, phone 13812345678")
+		l.With("err", errors.New("card 4111111111111111 declined")).Error("payment failed")
+
+		out := buf.String()
+		for _, secret := range []string{"s3cr3t", "4111", "13812345678"} {
+			if strings.Contains(out, secret) {
+				t.Fatalf("%T leaked %q: %s", encoder, secret, out)
+			}
+		}
+		if !strings.Contains(out, "text/plain") || !strings.Contains(out, "payment failed") {
+			t.Fatalf("%T should keep the other data: %s", encoder, out)
+		}
+	}
+}
+
+func TestRedactor_Header(t *testing.T) {
+	header := http.Header{}
+	header.Set("Set-Cookie", "a=b")
+	header.Set("X-Trace", "abc")
+	masked := NewRedactor().Header(header)
+	if masked.Get("Set-Cookie") != "******" || masked.Get("X-Trace") != "abc" {
+		t.Fatal("unexpected masked header", masked)
+	}
+	if header.Get("Set-Cookie") != "a=b" {
+		t.Fatal("the original header should be kept")
+	}
+}
+
+type redactUser struct {
+	Name     string            `json:"name"`
+	Token    string            `json:"token"`
+	Password string            `json:"-"`
+	Profile  map[string]string `json:"profile"`
+	Tags     []string          `json:"tags"`
+}
+
+func TestRedactor_Nested(t *testing.T) {
+	r := NewRedactor().Fields("password", "token").Patterns(PhoneNumberPattern)
+	value := map[string]interface{}{
+		"users": []*redactUser{{
+			Name:     "tom",
+			Token:    "s3cr3t-token",
+			Password: "s3cr3t-password",
+			Profile:  map[string]string{"password": "s3cr3t-profile", "city": "hz"},
+			Tags:     []string{"13812345678"},
+		}},
+		"cookie": "s3cr3t-cookie",
+	}
+	masked := r.Field("req", value).(map[string]interface{})
+	user := masked["users"].([]interface{})[0].(map[string]interface{})
+	profile := user["profile"].(map[string]interface{})
+	if user["name"] != "tom" || user["token"] != "******" || profile["password"] != "******" || profile["city"] != "hz" {
+		t.Fatal("nested fields should be masked", masked)
+	}
+	if _, exists := user["Password"]; exists || user["tags"].([]interface{})[0] != "******" || masked["cookie"] != "******" {
+		t.Fatal("unexpected masked value", masked)
+	}
+	if value["cookie"] != "s3cr3t-cookie" {
+		t.Fatal("the original value should be kept")
+	}
+
+	// the masked copy is what reaches the sinks
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetEncoder(JSONEncoder{})
+	l.SetRedactor(r)
+	l.With("req", value).Info("login")
+	if strings.Contains(buf.String(), "s3cr3t") || !strings.Contains(buf.String(), `"city":"hz"`) {
+		t.Fatal("nested secrets should not be logged", buf.String())
+	}
+}
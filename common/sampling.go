@@ -0,0 +1,152 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SamplingOptions logs the First records of the same message in every Tick,
+// then every Thereafter-th one, zero Thereafter drops the rest.
+type SamplingOptions struct {
+	Tick       time.Duration
+	First      int
+	Thereafter int
+}
+
+// SetSampling samples the records of the level, for all the derived loggers.
+func (l *Logger) SetSampling(level Level, options SamplingOptions) {
+	if options.Tick <= 0 {
+		options.Tick = time.Second
+	}
+	l.core.filterLock.Lock()
+	defer l.core.filterLock.Unlock()
+	l.core.samplers[level] = &sampler{options: options, counts: make(map[string]*sampleCount)}
+}
+
+// SetDedupe suppresses the records of the level repeating the previous one
+// within the window, a record with the repeat count is logged once it changes,
+// when the window ends, or on Sync.
+func (l *Logger) SetDedupe(level Level, window time.Duration) {
+	l.core.filterLock.Lock()
+	defer l.core.filterLock.Unlock()
+	d := &deduper{window: window}
+	core := l.core
+	d.schedule = func(after time.Duration) Timer {
+		return core.currentClock().AfterFunc(after, func() { core.flushDedupers(d) })
+	}
+	l.core.dedupers[level] = d
+}
+
+// filter redacts the entry, then returns the entries to log in place of it, maybe none
+func (c *logCore) filter(entry *Entry) []*Entry {
+	c.filterLock.Lock()
+	defer c.filterLock.Unlock()
+
+	if c.redactor != nil {
+		c.redactor.entry(entry)
+	} // if>
+	entries := []*Entry{entry}
+	if d, ok := c.dedupers[entry.Level]; ok {
+		entries = d.check(entry)
+	} // if>
+	if s, ok := c.samplers[entry.Level]; ok && len(entries) > 0 && entries[len(entries)-1] == entry {
+		if !s.check(entry) {
+			entries = entries[:len(entries)-1]
+		} // if>>
+	} // if>
+	return entries
+}
+
+// --- sampler ---
+type sampleCount struct {
+	start time.Time
+	n     int
+}
+
+type sampler struct {
+	options SamplingOptions
+	counts  map[string]*sampleCount
+}
+
+func (s *sampler) check(entry *Entry) bool {
+	count, ok := s.counts[entry.Message]
+	if !ok || entry.Time.Sub(count.start) >= s.options.Tick {
+		// forget the messages of the previous ticks
+		if !ok && len(s.counts) >= 1024 {
+			s.counts = make(map[string]*sampleCount)
+		} // if>>
+		count = &sampleCount{start: entry.Time}
+		s.counts[entry.Message] = count
+	} // if>
+	count.n++
+	if count.n <= s.options.First {
+		return true
+	} // if>
+	return s.options.Thereafter > 0 && (count.n-s.options.First)%s.options.Thereafter == 0
+}
+
+// flushDedupers logs the repeat counts of the expired deduper, or all of them if it is nil
+func (c *logCore) flushDedupers(expired *deduper) {
+	now := c.currentClock().Now()
+	c.filterLock.Lock()
+	entries := make([]*Entry, 0)
+	for _, d := range c.dedupers {
+		if expired != nil && d != expired {
+			continue
+		}
+		if summary := d.summary(now); summary != nil {
+			entries = append(entries, summary)
+		}
+	} // for>
+	c.filterLock.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Level < entries[j].Level })
+	c.send(entries)
+}
+
+// --- deduper ---
+type deduper struct {
+	window  time.Duration
+	last    *Entry
+	key     string
+	repeats int
+	// timer logs the repeat count when the window ends,
+	// it is scheduled on the clock of the logger, like the entry times
+	timer    Timer
+	schedule func(after time.Duration) Timer
+}
+
+func (d *deduper) check(entry *Entry) []*Entry {
+	key := entry.Message + "\x00" + fmt.Sprint(entry.Fields...)
+	if d.last != nil && key == d.key && entry.Time.Sub(d.last.Time) < d.window {
+		d.repeats++
+		if d.timer == nil && d.schedule != nil {
+			d.timer = d.schedule(d.last.Time.Add(d.window).Sub(entry.Time))
+		} // if>>
+		return nil
+	} // if>
+
+	entries := make([]*Entry, 0, 2)
+	if summary := d.summary(entry.Time); summary != nil {
+		entries = append(entries, summary)
+	} // if>
+	d.last, d.key = entry, key
+	return append(entries, entry)
+}
+
+// summary returns the record of the repeat count, and resets it, or nil without repeats
+func (d *deduper) summary(now time.Time) *Entry {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	} // if>
+	if d.repeats == 0 {
+		return nil
+	} // if>
+	summary := *d.last
+	summary.Time = now
+	summary.Stack = nil
+	summary.Message = fmt.Sprintf("last message repeated %d times: %s", d.repeats, d.last.Message)
+	d.repeats = 0
+	return &summary
+}
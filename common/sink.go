@@ -0,0 +1,93 @@
+package common
+
+import (
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"os"
+)
+
+// Sink is a destination of the log records, with its own minimum level.
+type Sink interface {
+	// Write writes an encoded record logged at level.
+	Write(level Level, record []byte) error
+	// Level returns the minimum level of the records written to the sink.
+	Level() Level
+}
+
+// Syncer is implemented by the sinks which buffer the records.
+type Syncer interface {
+	Sync() error
+}
+
+// --- writer sink ---
+type writerSink struct {
+	writer io.Writer
+	min    Level
+}
+
+// NewSink returns a Sink writing the records of min level and above to the writer.
+func NewSink(writer io.Writer, min Level) Sink {
+	return &writerSink{writer: writer, min: min}
+}
+
+func (w *writerSink) Write(level Level, record []byte) error {
+	_, err := w.writer.Write(record)
+	return err
+}
+
+// Sync flushes the writer if it supports Flush or Sync.
+func (w *writerSink) Sync() error {
+	switch v := w.writer.(type) {
+	case interface{ Flush() error }:
+		return v.Flush()
+	case *os.File:
+		// stdout and stderr cannot be synced when they are pipes or terminals
+		_ = v.Sync()
+		return nil
+	case interface{ Sync() error }:
+		return v.Sync()
+	}
+	return nil
+}
+
+func (w *writerSink) Level() Level {
+	return w.min
+}
+
+// StdoutSink returns a Sink writing to the standard output.
+func StdoutSink(min Level) Sink {
+	return NewSink(os.Stdout, min)
+}
+
+// StderrSink returns a Sink writing to the standard error.
+func StderrSink(min Level) Sink {
+	return NewSink(os.Stderr, min)
+}
+
+// --- file sink ---
+// FileOptions are the options of a rotated log file.
+type FileOptions struct {
+	Filename string
+	// megabytes before the file is rotated
+	MaxSize int
+	// days to keep the rotated files
+	MaxAge int
+	// number of the rotated files to keep
+	MaxBackups int
+	// gzip the rotated files
+	Compress bool
+	// name the rotated files in local time instead of UTC
+	LocalTime bool
+}
+
+// FileSink returns a Sink writing to a file rotated by size and age.
+func FileSink(options FileOptions, min Level) Sink {
+	return NewSink(&lumberjack.Logger{
+		Filename:   options.Filename,
+		MaxSize:    options.MaxSize,
+		MaxAge:     options.MaxAge,
+		MaxBackups: options.MaxBackups,
+		Compress:   options.Compress,
+		LocalTime:  options.LocalTime,
+	}, min)
+}
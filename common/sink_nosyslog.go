@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package common
+
+import "errors"
+
+// SyslogSink is not supported on this platform.
+func SyslogSink(tag string, min Level) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package common
+
+import "log/syslog"
+
+type syslogSink struct {
+	writer *syslog.Writer
+	min    Level
+}
+
+// SyslogSink returns a Sink writing to the local syslog daemon,
+// the severity of the messages follows their level.
+func SyslogSink(tag string, min Level) (Sink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer, min: min}, nil
+}
+
+func (s *syslogSink) Write(level Level, record []byte) error {
+	message := string(record)
+	switch level {
+	case LevelTrace, LevelDebug:
+		return s.writer.Debug(message)
+	case LevelInfo:
+		return s.writer.Info(message)
+	case LevelWarn:
+		return s.writer.Warning(message)
+	case LevelError:
+		return s.writer.Err(message)
+	default:
+		return s.writer.Crit(message)
+	}
+}
+
+func (s *syslogSink) Level() Level {
+	return s.min
+}
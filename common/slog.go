@@ -0,0 +1,169 @@
+package common
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// $--- level mapping ---
+// LevelTrace and LevelFatal are beyond the levels of slog
+const (
+	slogLevelTrace = slog.LevelDebug - 4
+	slogLevelFatal = slog.LevelError + 4
+)
+
+// SlogLevel returns the slog level of the level.
+func SlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelTrace:
+		return slogLevelTrace
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+// LevelOfSlog returns the level of the slog level.
+func LevelOfSlog(level slog.Level) Level {
+	switch {
+	case level <= slogLevelTrace:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < slogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// $--- Logger as slog.Handler ---
+// NewSlogHandler returns a slog.Handler writing to the logger,
+// the records go through the same level, filters, encoder and sinks.
+//
+//	slog.SetDefault(slog.New(common.NewSlogHandler(t.Logger)))
+func NewSlogHandler(logger *Logger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+type slogHandler struct {
+	logger *Logger
+	group  string
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	// the package levels may enable more, they are checked in Handle
+	h.logger.core.lock.RLock()
+	overridden := len(h.logger.core.pkgLevels) > 0
+	h.logger.core.lock.RUnlock()
+	return overridden || LevelOfSlog(level) >= h.logger.Level()
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	entry := &Entry{
+		Time:    r.Time,
+		Level:   LevelOfSlog(r.Level),
+		Message: r.Message,
+	}
+	pkg := ""
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		pkg = funcPackage(frame.Function)
+	} // if>
+	if entry.Level < h.logger.packageLevel(pkg) {
+		return nil
+	} // if>
+
+	fields := make([]interface{}, 0, len(h.logger.fields)+2*r.NumAttrs())
+	fields = append(fields, h.logger.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.group, a)
+		return true
+	})
+	entry.Fields = fields
+	h.logger.core.emit(entry)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]interface{}, 0, 2*len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.group, a)
+	}
+	return &slogHandler{logger: h.logger.With(fields...), group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, group: h.group + name + "."}
+}
+
+// flatten the attr into key-value pairs, the keys of a group are prefixed
+func appendAttr(fields []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	return append(fields, prefix+a.Key, a.Value.Any())
+}
+
+// $--- slog.Handler as backend ---
+// NewSlogLogger returns a logger handing its records to the slog handler,
+// instead of the encoder and the sinks.
+func NewSlogLogger(handler slog.Handler, debug bool) *Logger {
+	l := NewLoggerWithSinks("", debug)
+	l.SetSlogBackend(handler)
+	return l
+}
+
+// SetSlogBackend hands the records to the slog handler, for all the derived loggers,
+// nil switches back to the encoder and the sinks.
+func (l *Logger) SetSlogBackend(handler slog.Handler) {
+	l.core.writeLock.Lock()
+	defer l.core.writeLock.Unlock()
+	l.core.backend = handler
+}
+
+func toSlogRecord(entry *Entry) slog.Record {
+	r := slog.NewRecord(entry.Time, SlogLevel(entry.Level), entry.Message, 0)
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		key, _ := entry.Fields[i].(string)
+		if key == "" {
+			key = "!BADKEY"
+		}
+		r.AddAttrs(slog.Any(key, entry.Fields[i+1]))
+	} // for>
+	if entry.Caller != "" {
+		r.AddAttrs(slog.String("caller", entry.Caller))
+	} // if>
+	if len(entry.Stack) > 0 {
+		r.AddAttrs(slog.Any("stack", entry.Stack))
+	} // if>
+	return r
+}
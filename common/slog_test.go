@@ -0,0 +1,39 @@
+package common
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := newBufferLogger(buf, LevelInfo)
+	l.SetRedactor(NewRedactor().Fields("password"))
+	logger := slog.New(NewSlogHandler(l.With("app", "demo")))
+
+	logger.Debug("hidden")
+	logger.With("user", 7).WithGroup("req").Info("login", "method", "GET", "password", "s3cr3t")
+	out := buf.String()
+	if strings.Contains(out, "hidden") || strings.Contains(out, "s3cr3t") {
+		t.Fatal("records should go through the level and the redactor", out)
+	}
+	if !strings.Contains(out, "slog_test.go:") || !strings.Contains(out, "INFO login app=demo user=7 req.method=GET") {
+		t.Fatal("unexpected record", out)
+	}
+}
+
+func TestSlogBackend(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewSlogLogger(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), false)
+	l.With("user", 7).Warn("slow")
+	l.Debug("hidden")
+	out := buf.String()
+	if !strings.Contains(out, `"level":"WARN","msg":"slow","user":7,"caller":"slog_test.go:`) {
+		t.Fatal("records should be handed to the slog handler", out)
+	}
+	if strings.Contains(out, "hidden") {
+		t.Fatal("the level of the logger should apply", out)
+	}
+}
@@ -0,0 +1,437 @@
+package common
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// TaskFunc handles a task with its payload,
+// the task is retried if an error is returned.
+type TaskFunc func(payload []byte) error
+
+// Task is a unit of work in the TaskQueue.
+type Task struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Payload    []byte    `json:"payload"`
+	Priority   int       `json:"priority"`
+	RunAt      time.Time `json:"run_at"`
+	Attempts   int       `json:"attempts"`
+	MaxRetries int       `json:"max_retries"`
+	seq        uint64
+}
+
+// TaskOptions are the options of a single task.
+type TaskOptions struct {
+	// the higher priority runs first
+	Priority int
+	// run the task after the delay
+	Delay time.Duration
+	// retry the failed task at most MaxRetries times
+	MaxRetries int
+}
+
+// TaskMetrics are the counters of a TaskQueue.
+type TaskMetrics struct {
+	Enqueued  uint64 `json:"enqueued"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	Retried   uint64 `json:"retried"`
+	Rejected  uint64 `json:"rejected"`
+	Pending   int    `json:"pending"`
+	Running   int    `json:"running"`
+}
+
+// TaskStore persists the unfinished tasks, so they survive restarts.
+type TaskStore interface {
+	Save(tasks []*Task) error
+	Load() ([]*Task, error)
+}
+
+// TaskQueueOptions are the options of a TaskQueue, zero fields take defaults.
+type TaskQueueOptions struct {
+	Workers    int
+	Capacity   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Store      TaskStore
+	Clock      Clock
+}
+
+var (
+	ErrTaskQueueFull   = errors.New("task queue is full")
+	ErrTaskQueueClosed = errors.New("task queue is closed")
+)
+
+// TaskQueue runs fire-and-forget tasks in a bounded pool of workers.
+type TaskQueue struct {
+	options  TaskQueueOptions
+	handlers map[string]TaskFunc
+	ready    readyTasks
+	delayed  delayedTasks
+	running  map[string]*Task
+	metrics  TaskMetrics
+	seq      uint64
+	started  bool
+	closed   bool
+	// the tasks changed since they were last persisted
+	dirty   bool
+	stop    chan struct{}
+	workers sync.WaitGroup
+	lock    sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewTaskQueue returns a new TaskQueue instance.
+func NewTaskQueue(options TaskQueueOptions) *TaskQueue {
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	if options.Capacity <= 0 {
+		options.Capacity = 1024
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = time.Minute
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock
+	}
+	q := &TaskQueue{
+		options:  options,
+		handlers: make(map[string]TaskFunc),
+		running:  make(map[string]*Task),
+		stop:     make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// Register sets the handler of the named tasks.
+func (q *TaskQueue) Register(name string, fn TaskFunc) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.handlers[name] = fn
+}
+
+// Enqueue adds a task, it returns ErrTaskQueueFull if the queue is at capacity.
+func (q *TaskQueue) Enqueue(name string, payload []byte, options TaskOptions) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrTaskQueueClosed
+	} // if>
+	if _, exists := q.handlers[name]; !exists {
+		return fmt.Errorf("task %s is not registered", name)
+	} // if>
+	if q.pending() >= q.options.Capacity {
+		q.metrics.Rejected++
+		return ErrTaskQueueFull
+	} // if>
+
+	now := q.options.Clock.Now()
+	q.seq++
+	task := &Task{
+		ID:         fmt.Sprintf("%d-%d", now.UnixNano(), q.seq),
+		Name:       name,
+		Payload:    payload,
+		Priority:   options.Priority,
+		RunAt:      now.Add(options.Delay),
+		MaxRetries: options.MaxRetries,
+	}
+	q.push(task)
+	q.metrics.Enqueued++
+	q.dirty = true
+	return nil
+}
+
+// Start loads the persisted tasks and starts the workers,
+// or does nothing if already started.
+func (q *TaskQueue) Start() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.started || q.closed {
+		return nil
+	} // if>
+	q.started = true
+
+	if q.options.Store != nil {
+		tasks, err := q.options.Store.Load()
+		if err != nil {
+			return err
+		} // if>>
+		for _, task := range tasks {
+			q.push(task)
+		} // for>>
+	} // if>
+
+	for i := 0; i < q.options.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	} // for>
+	q.workers.Add(1)
+	go q.schedule(q.options.Clock.NewTicker(100 * time.Millisecond))
+	return nil
+}
+
+// Drain stops accepting tasks and waits for the queued ones to finish,
+// the unfinished tasks are persisted if ctx is done first.
+func (q *TaskQueue) Drain(ctx context.Context) error {
+	q.lock.Lock()
+	q.closed = true
+	q.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.lock.Lock()
+		for q.started && q.pending()+len(q.running) > 0 && ctx.Err() == nil {
+			q.cond.Wait()
+		} // for>>
+		q.lock.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// wake up the waiting goroutine
+		q.cond.Broadcast()
+	}
+	q.Stop()
+	return ctx.Err()
+}
+
+// Stop stops the workers once their current tasks are done,
+// the queued tasks are kept in the store.
+func (q *TaskQueue) Stop() {
+	q.lock.Lock()
+	q.closed = true
+	if q.started {
+		q.started = false
+		close(q.stop)
+	}
+	q.cond.Broadcast()
+	q.lock.Unlock()
+
+	q.workers.Wait()
+	q.persist()
+}
+
+// Metrics returns a snapshot of the counters.
+func (q *TaskQueue) Metrics() TaskMetrics {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	m := q.metrics
+	m.Pending = q.pending()
+	m.Running = len(q.running)
+	return m
+}
+
+func (q *TaskQueue) pending() int {
+	return len(q.ready) + len(q.delayed)
+}
+
+func (q *TaskQueue) push(task *Task) {
+	q.seq++
+	task.seq = q.seq
+	if task.RunAt.After(q.options.Clock.Now()) {
+		heap.Push(&q.delayed, task)
+	} else {
+		heap.Push(&q.ready, task)
+		q.cond.Signal()
+	} // else>
+}
+
+// persist the unfinished tasks if they changed, the running ones are included,
+// so a task interrupted by a crash runs again. The tasks are copied under the lock,
+// and saved out of it, by the scheduler every tick and by Stop.
+func (q *TaskQueue) persist() {
+	q.lock.Lock()
+	if q.options.Store == nil || !q.dirty {
+		q.lock.Unlock()
+		return
+	} // if>
+	tasks := make([]*Task, 0, q.pending()+len(q.running))
+	for _, list := range [][]*Task{q.ready, q.delayed} {
+		for _, task := range list {
+			copied := *task
+			tasks = append(tasks, &copied)
+		}
+	} // for>
+	for _, task := range q.running {
+		copied := *task
+		tasks = append(tasks, &copied)
+	} // for>
+	q.dirty = false
+	q.lock.Unlock()
+
+	if err := q.options.Store.Save(tasks); err != nil {
+		// try again on the next tick
+		q.lock.Lock()
+		q.dirty = true
+		q.lock.Unlock()
+	} // if>
+}
+
+// schedule moves the delayed tasks to the ready ones when they are due.
+func (q *TaskQueue) schedule(ticker Ticker) {
+	defer q.workers.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			q.lock.Lock()
+			now := q.options.Clock.Now()
+			for len(q.delayed) > 0 && !q.delayed[0].RunAt.After(now) {
+				heap.Push(&q.ready, heap.Pop(&q.delayed))
+				q.cond.Signal()
+			} // for>>
+			q.lock.Unlock()
+			q.persist()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+func (q *TaskQueue) work() {
+	defer q.workers.Done()
+	for {
+		q.lock.Lock()
+		for q.started && len(q.ready) == 0 {
+			q.cond.Wait()
+		} // for>>
+		if !q.started {
+			q.lock.Unlock()
+			return
+		} // if>>
+		task := heap.Pop(&q.ready).(*Task)
+		q.running[task.ID] = task
+		fn := q.handlers[task.Name]
+		q.lock.Unlock()
+
+		err := runTask(fn, task.Payload)
+
+		q.lock.Lock()
+		delete(q.running, task.ID)
+		task.Attempts++
+		if err == nil {
+			q.metrics.Succeeded++
+		} else if task.Attempts <= task.MaxRetries {
+			q.metrics.Retried++
+			task.RunAt = q.options.Clock.Now().Add(q.backoff(task.Attempts))
+			q.push(task)
+		} else {
+			q.metrics.Failed++
+		} // else>>
+		q.dirty = true
+		q.cond.Broadcast()
+		q.lock.Unlock()
+	} // for>
+}
+
+// the backoff doubles on every attempt, until the max backoff
+func (q *TaskQueue) backoff(attempts int) time.Duration {
+	backoff := q.options.Backoff
+	for i := 1; i < attempts && backoff < q.options.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > q.options.MaxBackoff {
+		backoff = q.options.MaxBackoff
+	}
+	return backoff
+}
+
+// run the task, a panic is recovered as an error
+func runTask(fn TaskFunc, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if fn == nil {
+		return errors.New("task handler not found")
+	}
+	return fn(payload)
+}
+
+// --- ready tasks, the higher priority first ---
+type readyTasks []*Task
+
+func (r readyTasks) Len() int { return len(r) }
+func (r readyTasks) Less(i, j int) bool {
+	if r[i].Priority != r[j].Priority {
+		return r[i].Priority > r[j].Priority
+	}
+	return r[i].seq < r[j].seq
+}
+func (r readyTasks) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *readyTasks) Push(x interface{}) { *r = append(*r, x.(*Task)) }
+func (r *readyTasks) Pop() interface{} {
+	old := *r
+	task := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return task
+}
+
+// --- delayed tasks, the earlier first ---
+type delayedTasks []*Task
+
+func (d delayedTasks) Len() int            { return len(d) }
+func (d delayedTasks) Less(i, j int) bool  { return d[i].RunAt.Before(d[j].RunAt) }
+func (d delayedTasks) Swap(i, j int)       { d[i], d[j] = d[j], d[i] }
+func (d *delayedTasks) Push(x interface{}) { *d = append(*d, x.(*Task)) }
+func (d *delayedTasks) Pop() interface{} {
+	old := *d
+	task := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return task
+}
+
+// --- file task store ---
+// FileTaskStore is a TaskStore keeping the tasks in a JSON file.
+type FileTaskStore struct {
+	fileName string
+}
+
+// NewFileTaskStore returns a new FileTaskStore instance.
+func NewFileTaskStore(fileName string) *FileTaskStore {
+	return &FileTaskStore{fileName: fileName}
+}
+
+func (f *FileTaskStore) Save(tasks []*Task) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	} // if>
+	// write aside and rename, so a crash never leaves a broken file
+	tmp := f.fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	} // if>
+	return os.Rename(tmp, f.fileName)
+}
+
+func (f *FileTaskStore) Load() ([]*Task, error) {
+	data, err := ioutil.ReadFile(f.fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} // if>
+	if err != nil {
+		return nil, err
+	} // if>
+	tasks := make([]*Task, 0)
+	return tasks, json.Unmarshal(data, &tasks)
+}
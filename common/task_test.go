@@ -0,0 +1,147 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTaskQueue_Priority(t *testing.T) {
+	q := NewTaskQueue(TaskQueueOptions{Workers: 1})
+	var lock sync.Mutex
+	order := make([]string, 0)
+	q.Register("echo", func(payload []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, string(payload))
+		return nil
+	})
+
+	// queued before start, so the priority decides the order
+	_ = q.Enqueue("echo", []byte("low"), TaskOptions{})
+	_ = q.Enqueue("echo", []byte("high"), TaskOptions{Priority: 10})
+	_ = q.Enqueue("echo", []byte("mid"), TaskOptions{Priority: 5})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 3 || order[0] != "high" || order[1] != "mid" || order[2] != "low" {
+		t.Fatal("tasks should run by priority", order)
+	}
+	if m := q.Metrics(); m.Enqueued != 3 || m.Succeeded != 3 || m.Pending != 0 {
+		t.Fatal("metrics should count the tasks", m)
+	}
+	if err := q.Enqueue("echo", nil, TaskOptions{}); err != ErrTaskQueueClosed {
+		t.Fatal("drained queue should reject tasks", err)
+	}
+}
+
+func TestTaskQueue_Retry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	q := NewTaskQueue(TaskQueueOptions{Workers: 1, Backoff: time.Second, Clock: clock})
+	attempts := make(chan int, 3)
+	n := 0
+	q.Register("flaky", func(payload []byte) error {
+		n++
+		attempts <- n
+		if n < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	_ = q.Start()
+	defer q.Stop()
+	_ = q.Enqueue("flaky", nil, TaskOptions{MaxRetries: 2})
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case got := <-attempts:
+			if got != i {
+				t.Fatal("unexpected attempt", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("task should be retried", i)
+		}
+		// wait for the retry to be scheduled, then move past its backoff
+		for q.Metrics().Running > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(10 * time.Second)
+	}
+	for q.Metrics().Succeeded != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if m := q.Metrics(); m.Retried != 2 || m.Failed != 0 {
+		t.Fatal("metrics should count the retries", m)
+	}
+}
+
+func TestTaskQueue_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tong-task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileTaskStore(filepath.Join(dir, "tasks.json"))
+
+	// queued but never started
+	q := NewTaskQueue(TaskQueueOptions{Store: store})
+	q.Register("mail", func(payload []byte) error { return nil })
+	_ = q.Enqueue("mail", []byte("hi"), TaskOptions{})
+	q.Stop()
+
+	done := make(chan string, 1)
+	q = NewTaskQueue(TaskQueueOptions{Store: store})
+	q.Register("mail", func(payload []byte) error {
+		done <- string(payload)
+		return nil
+	})
+	_ = q.Start()
+	defer q.Stop()
+	select {
+	case payload := <-done:
+		if payload != "hi" {
+			t.Fatal("unexpected payload", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("persisted task should run after restart")
+	}
+}
+
+type countingStore struct {
+	saves int
+	tasks []*Task
+}
+
+func (s *countingStore) Save(tasks []*Task) error {
+	s.saves++
+	s.tasks = tasks
+	return nil
+}
+
+func (s *countingStore) Load() ([]*Task, error) { return nil, nil }
+
+func TestTaskQueue_PersistBatched(t *testing.T) {
+	store := &countingStore{}
+	q := NewTaskQueue(TaskQueueOptions{Store: store, Clock: NewFakeClock(time.Now())})
+	q.Register("mail", func(payload []byte) error { return nil })
+	for i := 0; i < 100; i++ {
+		_ = q.Enqueue("mail", nil, TaskOptions{})
+	}
+	if store.saves != 0 {
+		t.Fatal("enqueue should not write the store", store.saves)
+	}
+	// saved once on stop, with the copies of the tasks
+	q.Stop()
+	if store.saves != 1 || len(store.tasks) != 100 {
+		t.Fatal("stop should save the tasks once", store.saves, len(store.tasks))
+	}
+}
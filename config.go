@@ -0,0 +1,415 @@
+package tong
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/ming3000/tong/common"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// $--- config define ---
+// Config is the config of a Tong application,
+// it is loaded from a file, the environment variables and the command-line flags.
+type Config struct {
+	Debug      bool               `json:"debug" yaml:"debug" toml:"debug"`
+	Server     ServerConfig       `json:"server" yaml:"server" toml:"server"`
+	Log        LogConfig          `json:"log" yaml:"log" toml:"log"`
+	Middleware []MiddlewareConfig `json:"middleware" yaml:"middleware" toml:"middleware"`
+}
+
+type ServerConfig struct {
+	Addr              string    `json:"addr" yaml:"addr" toml:"addr"`
+	ReadTimeout       Duration  `json:"read_timeout" yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration  `json:"read_header_timeout" yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration  `json:"write_timeout" yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration  `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"`
+	TLS               TLSConfig `json:"tls" yaml:"tls" toml:"tls"`
+}
+
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file" toml:"key_file"`
+}
+
+type LogConfig struct {
+	// trace, debug, info, warn, error or fatal, it defaults to debug in debug mode
+	Level string `json:"level" yaml:"level" toml:"level"`
+	// console, json or logfmt
+	Format     string `json:"format" yaml:"format" toml:"format"`
+	Prefix     string `json:"prefix" yaml:"prefix" toml:"prefix"`
+	Stdout     bool   `json:"stdout" yaml:"stdout" toml:"stdout"`
+	File       string `json:"file" yaml:"file" toml:"file"`
+	MaxSize    int    `json:"max_size" yaml:"max_size" toml:"max_size"`
+	MaxAge     int    `json:"max_age" yaml:"max_age" toml:"max_age"`
+	MaxBackups int    `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	Compress   bool   `json:"compress" yaml:"compress" toml:"compress"`
+}
+
+// MiddlewareConfig names a registered middleware and its options.
+type MiddlewareConfig struct {
+	Name    string                 `json:"name" yaml:"name" toml:"name"`
+	Options map[string]interface{} `json:"options" yaml:"options" toml:"options"`
+}
+
+// Duration is a time.Duration written as "5s" in the config.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultConfig returns the config used by New.
+func DefaultConfig() *Config {
+	return &Config{
+		Debug: false,
+		Server: ServerConfig{
+			Addr: ":3000",
+		},
+		Log: LogConfig{
+			Format:     "console",
+			Prefix:     "tong says:",
+			Stdout:     true,
+			MaxSize:    4,
+			MaxAge:     1,
+			MaxBackups: 1,
+		},
+	}
+}
+
+// $--- loading ---
+// the prefix of the environment variables, e.g. TONG_SERVER_ADDR
+const configEnvPrefix = "TONG_"
+
+// LoadConfig returns the default config overridden by the file at path if not empty,
+// then the environment variables, then the command-line args,
+// the format of the file is decided by its extension: .json, .yaml, .yml or .toml
+//
+// every option is named after its path, e.g. server.read_timeout is set by
+// the environment variable TONG_SERVER_READ_TIMEOUT or the flag -server.read_timeout
+func LoadConfig(path string, args []string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := decodeConfigFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	options := configOptions(cfg)
+	for _, o := range options {
+		name := configEnvPrefix + strings.ToUpper(strings.Replace(o.name, ".", "_", -1))
+		if value, ok := os.LookupEnv(name); ok {
+			if err := o.Set(value); err != nil {
+				return nil, fmt.Errorf("config env %s: %v", name, err)
+			}
+		}
+	}
+
+	if args != nil {
+		flags := flag.NewFlagSet("tong", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		for _, o := range options {
+			flags.Var(o, o.name, "")
+		}
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("config flags: %v", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func decodeConfigFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	} // if>
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: unknown format", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	} // if>
+	return nil
+}
+
+// configOption is a scalar field of the config, it implements flag.Value
+type configOption struct {
+	name  string
+	value reflect.Value
+}
+
+// configOptions returns the scalar fields of the struct, named by their json tags
+func configOptions(cfg interface{}) []*configOption {
+	options := make([]*configOption, 0)
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := prefix + strings.Split(field.Tag.Get("json"), ",")[0]
+			switch field.Type.Kind() {
+			case reflect.Struct:
+				walk(name+".", v.Field(i))
+			case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+				options = append(options, &configOption{name: name, value: v.Field(i)})
+			}
+		} // for>
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return options
+}
+
+func (o *configOption) String() string {
+	if !o.value.IsValid() {
+		return ""
+	}
+	if d, ok := o.value.Interface().(Duration); ok {
+		return time.Duration(d).String()
+	}
+	return fmt.Sprint(o.value.Interface())
+}
+
+func (o *configOption) Set(s string) error {
+	if _, ok := o.value.Interface().(Duration); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		o.value.SetInt(int64(d))
+		return nil
+	}
+	switch o.value.Kind() {
+	case reflect.String:
+		o.value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		o.value.SetBool(b)
+	default:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		o.value.SetInt(n)
+	}
+	return nil
+}
+
+// IsBoolFlag allows -debug instead of -debug=true
+func (o *configOption) IsBoolFlag() bool {
+	return o.value.Kind() == reflect.Bool
+}
+
+// $--- validation ---
+// Validate checks the config, all the problems are reported in the error.
+func (c *Config) Validate() error {
+	problems := make([]string, 0)
+	if c.Server.Addr == "" {
+		problems = append(problems, "server.addr is empty")
+	}
+	for name, d := range map[string]Duration{
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+	} {
+		if d < 0 {
+			problems = append(problems, name+" is negative")
+		}
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		problems = append(problems, "server.tls needs both cert_file and key_file")
+	}
+	if c.Log.Level != "" {
+		if _, err := common.ParseLevel(c.Log.Level); err != nil {
+			problems = append(problems, "log.level: "+err.Error())
+		}
+	}
+	switch c.Log.Format {
+	case "", "console", "json", "logfmt":
+	default:
+		problems = append(problems, fmt.Sprintf("log.format: unknown format %q", c.Log.Format))
+	}
+	for i, m := range c.Middleware {
+		if _, ok := lookupMiddlewareFactory(m.Name); !ok {
+			problems = append(problems, fmt.Sprintf("middleware[%d]: %q is not registered", i, m.Name))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("invalid config: " + strings.Join(problems, "; "))
+}
+
+// $--- middleware registry ---
+// MiddlewareFactory builds a middleware from its options in the config.
+type MiddlewareFactory func(options map[string]interface{}) (MiddlewareFunc, error)
+
+var (
+	middlewareFactories    = map[string]MiddlewareFactory{}
+	middlewareFactoriesMux sync.RWMutex
+)
+
+// RegisterMiddleware makes a middleware available to the config by name,
+// the middleware package registers its middleware when it is imported.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareFactoriesMux.Lock()
+	defer middlewareFactoriesMux.Unlock()
+	middlewareFactories[name] = factory
+}
+
+func lookupMiddlewareFactory(name string) (MiddlewareFactory, bool) {
+	middlewareFactoriesMux.RLock()
+	defer middlewareFactoriesMux.RUnlock()
+	f, ok := middlewareFactories[name]
+	return f, ok
+}
+
+// $--- wiring ---
+// FromConfig applies the config to the server, the logger and the middleware,
+// calling it again replaces the middleware of the previous config in place.
+func (t *Tong) FromConfig(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	logger, err := NewLoggerFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	middleware, err := newConfigMiddleware(cfg)
+	if err != nil {
+		return err
+	}
+
+	t.Debug = cfg.Debug
+	t.Logger = logger
+	t.Server.Addr = cfg.Server.Addr
+	t.Server.ReadTimeout = time.Duration(cfg.Server.ReadTimeout)
+	t.Server.ReadHeaderTimeout = time.Duration(cfg.Server.ReadHeaderTimeout)
+	t.Server.WriteTimeout = time.Duration(cfg.Server.WriteTimeout)
+	t.Server.IdleTimeout = time.Duration(cfg.Server.IdleTimeout)
+	t.tlsCertFile, t.tlsKeyFile = cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile
+	t.setConfigMiddleware(middleware)
+	t.config = cfg
+	return nil
+}
+
+// the middleware built by the factories of the config
+func newConfigMiddleware(cfg *Config) ([]MiddlewareFunc, error) {
+	middleware := make([]MiddlewareFunc, 0, len(cfg.Middleware))
+	for _, m := range cfg.Middleware {
+		factory, ok := lookupMiddlewareFactory(m.Name)
+		if !ok {
+			return nil, fmt.Errorf("middleware %s is not registered", m.Name)
+		}
+		mw, err := factory(m.Options)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %v", m.Name, err)
+		}
+		middleware = append(middleware, mw)
+	} // for>
+	return middleware, nil
+}
+
+// setConfigMiddleware swaps the middleware of the config atomically,
+// they run among the customer middleware, where the first config is applied.
+func (t *Tong) setConfigMiddleware(middleware []MiddlewareFunc) {
+	first := t.configMiddleware.Load() == nil
+	t.configMiddleware.Store(middleware)
+	if first {
+		t.AddCustomerMiddleware(t.runConfigMiddleware)
+	}
+}
+
+func (t *Tong) runConfigMiddleware(next HandlerFunc) HandlerFunc {
+	return func(c *Context) error {
+		middleware := t.configMiddleware.Load().([]MiddlewareFunc)
+		return prependMiddleware(next, middleware...)(c)
+	}
+}
+
+// NewLoggerFromConfig returns the logger described by the log config.
+func NewLoggerFromConfig(cfg *Config) (*common.Logger, error) {
+	sinks := make([]common.Sink, 0, 2)
+	if cfg.Log.Stdout {
+		sinks = append(sinks, common.StdoutSink(common.LevelTrace))
+	}
+	if cfg.Log.File != "" {
+		sinks = append(sinks, common.FileSink(common.FileOptions{
+			Filename:   cfg.Log.File,
+			MaxSize:    cfg.Log.MaxSize,
+			MaxAge:     cfg.Log.MaxAge,
+			MaxBackups: cfg.Log.MaxBackups,
+			Compress:   cfg.Log.Compress,
+		}, common.LevelTrace))
+	}
+	logger := common.NewLoggerWithSinks(cfg.Log.Prefix, cfg.Debug, sinks...)
+
+	level, err := cfg.LogLevel()
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+	switch cfg.Log.Format {
+	case "json":
+		logger.SetEncoder(common.JSONEncoder{})
+	case "logfmt":
+		logger.SetEncoder(common.LogfmtEncoder{})
+	}
+	return logger, nil
+}
+
+// LogLevel returns the level of the log config,
+// debug in debug mode and info otherwise if it is not set.
+func (c *Config) LogLevel() (common.Level, error) {
+	if c.Log.Level != "" {
+		return common.ParseLevel(c.Log.Level)
+	}
+	if c.Debug {
+		return common.LevelDebug, nil
+	}
+	return common.LevelInfo, nil
+}
+
+// Config returns the config applied by FromConfig or reloaded by WatchConfig, or nil.
+func (t *Tong) Config() *Config {
+	if t.configWatcher != nil {
+		return t.configWatcher.Config()
+	}
+	return t.config
+}
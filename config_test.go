@@ -0,0 +1,133 @@
+package tong_test
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	_ "github.com/ming3000/tong/middleware"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	files := map[string]string{
+		"app.yaml": "server:\n  addr: \":8080\"\n  read_timeout: 5s\nlog:\n  level: warn\n",
+		"app.json": `{"server": {"addr": ":8080", "read_timeout": "5s"}, "log": {"level": "warn"}}`,
+		"app.toml": "[server]\naddr = \":8080\"\nread_timeout = \"5s\"\n[log]\nlevel = \"warn\"\n",
+	}
+	for name, content := range files {
+		cfg, err := tong.LoadConfig(writeConfig(t, name, content), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Server.Addr != ":8080" || cfg.Server.ReadTimeout != tong.Duration(5*time.Second) || cfg.Log.Level != "warn" {
+			t.Errorf("%s: config = %+v", name, cfg)
+		}
+		// the options missing in the file keep the defaults
+		if cfg.Log.Format != "console" || cfg.Debug {
+			t.Errorf("%s: log.format = %q, debug = %v, want the defaults", name, cfg.Log.Format, cfg.Debug)
+		}
+	}
+}
+
+func TestLoadConfig_Overrides(t *testing.T) {
+	path := writeConfig(t, "app.yaml", "server:\n  addr: \":8080\"\n  write_timeout: 1s\n")
+	t.Setenv("TONG_SERVER_ADDR", ":9090")
+	t.Setenv("TONG_SERVER_WRITE_TIMEOUT", "2s")
+
+	cfg, err := tong.LoadConfig(path, []string{"-server.write_timeout=3s", "-debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the environment overrides the file, and the flags override the environment
+	if cfg.Server.Addr != ":9090" {
+		t.Errorf("server.addr = %q", cfg.Server.Addr)
+	}
+	if cfg.Server.WriteTimeout != tong.Duration(3*time.Second) || !cfg.Debug {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	if _, err := tong.LoadConfig("", []string{"-unknown=1"}); err == nil {
+		t.Error("an unknown flag is accepted")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	path := writeConfig(t, "app.yaml", strings.Join([]string{
+		"server:",
+		"  addr: \"\"",
+		"  tls:",
+		"    cert_file: cert.pem",
+		"log:",
+		"  level: loud",
+		"middleware:",
+		"  - name: nothing",
+	}, "\n"))
+	_, err := tong.LoadConfig(path, nil)
+	if err == nil {
+		t.Fatal("an invalid config is accepted")
+	}
+	for _, problem := range []string{"server.addr", "server.tls", "log.level", `"nothing"`} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q does not report %s", err, problem)
+		}
+	}
+}
+
+func TestTong_FromConfig(t *testing.T) {
+	cfg := tong.DefaultConfig()
+	cfg.Server.Addr = ":8443"
+	cfg.Server.IdleTimeout = tong.Duration(time.Minute)
+	cfg.Log.Stdout, cfg.Log.File, cfg.Log.Format = false, "", "json"
+	cfg.Middleware = []tong.MiddlewareConfig{
+		{Name: "logger", Options: map[string]interface{}{"headers": []interface{}{"X-Token"}}},
+	}
+
+	app := tongtest.NewApp()
+	if err := app.FromConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if app.Server.Addr != ":8443" || app.Server.IdleTimeout != time.Minute || app.Config() != cfg {
+		t.Errorf("server = %+v", app.Server)
+	}
+
+	buf := new(bytes.Buffer)
+	app.Logger.SetOutput(buf)
+	app.GET("/ping", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "pong")
+	})
+	tongtest.NewClient(app).GET("/ping").Header("X-Token", "secret").Do(t).ExpectStatus(http.StatusOK)
+	if !strings.Contains(buf.String(), `"msg":"access"`) || !strings.Contains(buf.String(), "X-Token") {
+		t.Errorf("access log = %s", buf.String())
+	}
+
+	// the middleware is not added twice
+	buf.Reset()
+	if err := app.FromConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	app.Logger.SetOutput(buf)
+	tongtest.NewClient(app).GET("/ping").Do(t).ExpectStatus(http.StatusOK)
+	if n := strings.Count(buf.String(), `"msg":"access"`); n != 1 {
+		t.Errorf("access logs = %d, want 1", n)
+	}
+
+	cfg.Middleware[0].Options = map[string]interface{}{"colour": "red"}
+	if err := tongtest.NewApp().FromConfig(cfg); err == nil {
+		t.Error("an unknown middleware option is accepted")
+	}
+}
@@ -0,0 +1,216 @@
+package tong
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the changes of a file in this period are reloaded once,
+// an editor usually writes a file in several steps
+const configReloadDelay = 100 * time.Millisecond
+
+// ConfigWatcher reloads the config when its file changes,
+// the subscribers are notified with the new config,
+// and an invalid new config is rejected while the previous one is kept.
+type ConfigWatcher struct {
+	path        string
+	args        []string
+	current     atomic.Value
+	subscribers []func(old, new *Config)
+	onError     func(err error)
+	lock        sync.Mutex
+	stop        chan struct{}
+}
+
+// NewConfigWatcher loads the config as LoadConfig does, and returns a watcher of it.
+func NewConfigWatcher(path string, args []string) (*ConfigWatcher, error) {
+	cfg, err := LoadConfig(path, args)
+	if err != nil {
+		return nil, err
+	}
+	w := &ConfigWatcher{path: path, args: args}
+	w.current.Store(cfg)
+	return w, nil
+}
+
+// Config returns the current config, it must not be modified.
+func (w *ConfigWatcher) Config() *Config {
+	return w.current.Load().(*Config)
+}
+
+// Subscribe calls fn with the previous and the new config after every reload.
+func (w *ConfigWatcher) Subscribe(fn func(old, new *Config)) *ConfigWatcher {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+	return w
+}
+
+// OnError calls fn when a changed config can not be loaded.
+func (w *ConfigWatcher) OnError(fn func(err error)) *ConfigWatcher {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.onError = fn
+	return w
+}
+
+// Reload loads the config again, the current config is kept if it fails.
+// The callbacks run out of the lock, so they may use the watcher.
+func (w *ConfigWatcher) Reload() error {
+	w.lock.Lock()
+	cfg, err := LoadConfig(w.path, w.args)
+	if err != nil {
+		onError := w.onError
+		w.lock.Unlock()
+		if onError != nil {
+			onError(err)
+		}
+		return err
+	} // if>
+
+	old := w.Config()
+	w.current.Store(cfg)
+	subscribers := append([]func(old, new *Config){}, w.subscribers...)
+	w.lock.Unlock()
+	for _, fn := range subscribers {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+// Start watches the file in a goroutine,
+// with inotify on Linux and by polling elsewhere.
+func (w *ConfigWatcher) Start() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stop != nil {
+		return nil
+	} // if>
+
+	changes := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	if err := watchFile(w.path, changes, stop); err != nil {
+		return err
+	}
+	w.stop = stop
+	go w.run(changes, stop)
+	return nil
+}
+
+// Stop stops watching the file, a reload in progress is not waited,
+// so it may be called by the callbacks.
+func (w *ConfigWatcher) Stop() {
+	w.lock.Lock()
+	stop := w.stop
+	w.stop = nil
+	w.lock.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+}
+
+func (w *ConfigWatcher) run(changes <-chan struct{}, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-changes:
+		}
+		// wait for the rest of the writes
+		select {
+		case <-stop:
+			return
+		case <-time.After(configReloadDelay):
+		}
+		select {
+		case <-changes:
+		default:
+		}
+		_ = w.Reload()
+	} // for>
+}
+
+// notify the change without blocking, the changes not reloaded yet are merged
+func notifyChange(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// statFile returns the info of the file following the symlinks, nil if it is missing
+func statFile(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// fileChanged reports whether the file is replaced, e.g. by a symlink swap of a Kubernetes ConfigMap,
+// or its modification time or size changed
+func fileChanged(old, new os.FileInfo) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return !os.SameFile(old, new) || !old.ModTime().Equal(new.ModTime()) || old.Size() != new.Size()
+}
+
+// pollFile notifies the changes of the file.
+func pollFile(path string, interval time.Duration, changes chan<- struct{}, stop <-chan struct{}) {
+	last := statFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if info := statFile(path); fileChanged(last, info) {
+			last = info
+			notifyChange(changes)
+		} // if>
+	} // for>
+}
+
+// $--- Tong ---
+// WatchConfig loads and applies the config, then reloads it when the file changes,
+// the level of the logger and the middleware of the config, e.g. the rate limits,
+// the CORS origins and the blacklist, follow the reloaded config.
+//
+// the other options take effect on restart,
+// subscribe to the watcher for the options of your own code.
+func (t *Tong) WatchConfig(path string, args []string) (*ConfigWatcher, error) {
+	w, err := NewConfigWatcher(path, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.FromConfig(w.Config()); err != nil {
+		return nil, err
+	}
+
+	logger := t.Logger
+	w.Subscribe(func(old, new *Config) {
+		if level, err := new.LogLevel(); err == nil {
+			logger.SetLevel(level)
+		}
+		// the middleware are rebuilt, the previous ones are kept if they fail
+		if middleware, err := newConfigMiddleware(new); err != nil {
+			logger.Warn("config middleware rejected, keep the previous ones: " + err.Error())
+		} else {
+			t.setConfigMiddleware(middleware)
+		}
+		logger.Info("config reloaded: " + path)
+	})
+	w.OnError(func(err error) {
+		logger.Warn("config rejected, keep the previous one: " + err.Error())
+	})
+	if err := w.Start(); err != nil {
+		return nil, err
+	}
+	t.configWatcher = w
+	return w, nil
+}
@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package tong
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// watchFile notifies the changes of the file with inotify,
+// it watches the directory as editors usually replace the file by renaming,
+// and a Kubernetes ConfigMap replaces the ..data symlink which the file links through.
+func watchFile(path string, changes chan<- struct{}, stop <-chan struct{}) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		// inotify may be unavailable or out of watches
+		go pollFile(path, time.Second, changes, stop)
+		return nil
+	} // if>
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return err
+	} // if>
+
+	// a nonblocking fd goes to the runtime poller, so Close interrupts Read
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-stop
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 4096)
+		last := statFile(path)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			matched := false
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				if s := strings.TrimRight(string(nameBytes), "\x00"); s == name || s == "..data" {
+					matched = true
+				} // if>>
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+			} // for>>
+			// the other events may still swap a symlink in the path of the file
+			if info := statFile(path); matched || fileChanged(last, info) {
+				last = info
+				notifyChange(changes)
+			} // if>>
+		} // for>
+	}()
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package tong
+
+import "time"
+
+// watchFile notifies the changes of the file by polling.
+func watchFile(path string, changes chan<- struct{}, stop <-chan struct{}) error {
+	go pollFile(path, time.Second, changes, stop)
+	return nil
+}
@@ -0,0 +1,189 @@
+package tong_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"github.com/ming3000/tong/tongtest"
+)
+
+const watchedConfig = "log:\n  stdout: false\n  file: \"\"\n  level: %s\n"
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTong_WatchConfig(t *testing.T) {
+	path := writeConfig(t, "app.yaml", fmt.Sprintf(watchedConfig, "info"))
+	app := tongtest.NewApp()
+	w, err := app.WatchConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	reloaded := make(chan *tong.Config, 4)
+	w.Subscribe(func(old, new *tong.Config) { reloaded <- new })
+	rejected := make(chan error, 4)
+	w.OnError(func(err error) { rejected <- err })
+	if app.Logger.Level() != common.LevelInfo {
+		t.Fatalf("level = %v", app.Logger.Level())
+	}
+
+	// written in place
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(watchedConfig, "warn")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the reload", func() bool { return app.Logger.Level() == common.LevelWarn })
+	if cfg := <-reloaded; cfg.Log.Level != "warn" || app.Config() != cfg {
+		t.Errorf("reloaded config = %+v", cfg)
+	}
+
+	// an invalid config is rejected
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(watchedConfig, "loud")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rejected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the invalid config is not rejected")
+	}
+	if app.Config().Log.Level != "warn" || app.Logger.Level() != common.LevelWarn {
+		t.Errorf("the previous config is not kept: %+v", app.Config().Log)
+	}
+
+	// replaced by renaming, as editors do
+	tmp := filepath.Join(filepath.Dir(path), "app.yaml.tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf(watchedConfig, "error")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the reload after rename", func() bool { return app.Logger.Level() == common.LevelError })
+}
+
+func TestConfigWatcher_Callbacks(t *testing.T) {
+	path := writeConfig(t, "app.yaml", fmt.Sprintf(watchedConfig, "info"))
+	w, err := tong.NewConfigWatcher(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the callbacks may use the watcher
+	calls := 0
+	w.Subscribe(func(old, new *tong.Config) {
+		calls++
+		w.Subscribe(func(old, new *tong.Config) {})
+		w.OnError(func(err error) {})
+		w.Stop()
+	})
+	done := make(chan error, 1)
+	go func() { done <- w.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil || calls != 1 {
+			t.Fatal(err, calls)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the callbacks deadlock the watcher")
+	}
+}
+
+func TestConfigWatcher_ConfigMap(t *testing.T) {
+	// the layout of a mounted Kubernetes ConfigMap
+	dir := t.TempDir()
+	writeVersion := func(version, level string) {
+		if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "app.yaml"), []byte(fmt.Sprintf(watchedConfig, level)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..v1", "info")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.yaml")
+	if err := os.Symlink(filepath.Join("..data", "app.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := tong.NewConfigWatcher(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// the update swaps the ..data symlink
+	writeVersion("..v2", "warn")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the reload after the symlink swap", func() bool { return w.Config().Log.Level == "warn" })
+}
+
+const watchedMiddleware = `log:
+  stdout: false
+middleware:
+  - name: cors
+    options:
+      origins: [%q]
+  - name: ratelimit
+    options:
+      rate: %d
+      burst: %d
+`
+
+func TestTong_WatchConfigMiddleware(t *testing.T) {
+	path := writeConfig(t, "app.yaml", fmt.Sprintf(watchedMiddleware, "https://a.com", 1000, 1000))
+	app := tongtest.NewApp()
+	w, err := app.WatchConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	app.GET("/ping", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "pong")
+	})
+	client := tongtest.NewClient(app)
+	allowed := func(origin string) string {
+		return client.GET("/ping").Header("Origin", origin).Do(t).
+			ExpectStatus(http.StatusOK).Header().Get("Access-Control-Allow-Origin")
+	}
+	if allowed("https://a.com") != "https://a.com" || allowed("https://b.com") != "" {
+		t.Fatal("the origins of the config should be allowed")
+	}
+
+	// the new origins and limits apply without a restart
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(watchedMiddleware, "https://b.com", 1, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the new origins", func() bool {
+		return client.GET("/ping").Header("Origin", "https://b.com").Do(t).Header().Get("Access-Control-Allow-Origin") == "https://b.com"
+	})
+	client.GET("/ping").Do(t).ExpectStatus(http.StatusTooManyRequests)
+}
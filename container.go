@@ -0,0 +1,406 @@
+package tong
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Scope is the lifetime of a provided value.
+type Scope int
+
+const (
+	// ScopeSingleton values are made once, and closed on Shutdown
+	ScopeSingleton Scope = iota
+	// ScopeRequest values are made once per request, and closed after it
+	ScopeRequest
+)
+
+func (s Scope) String() string {
+	if s == ScopeRequest {
+		return "request"
+	}
+	return "singleton"
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*Context)(nil))
+)
+
+// $--- container ---
+// Container makes the values of the types by their constructors,
+// resolving the parameters of the constructors by their types.
+// The lock guards the registrations only, the constructors run out of it.
+type Container struct {
+	lock       sync.Mutex
+	providers  map[reflect.Type]*provider
+	singletons map[reflect.Type]*instance
+	// the types checked to be free of the dependency cycles, reset by Provide
+	acyclic map[reflect.Type]bool
+	// onClose registers the close functions of the singletons
+	onClose func(fn func() error)
+}
+
+type provider struct {
+	constructor reflect.Value
+	scope       Scope
+	// the close function and the error are the optional outputs after the value
+	closeIndex int
+	errorIndex int
+}
+
+// instance is a value made once by its provider, its lock is held while it is made
+type instance struct {
+	lock  sync.Mutex
+	done  bool
+	value reflect.Value
+}
+
+// NewContainer returns a new Container instance,
+// onClose receives the close functions of the singletons, it may be nil.
+func NewContainer(onClose func(fn func() error)) *Container {
+	return &Container{
+		providers:  make(map[reflect.Type]*provider),
+		singletons: make(map[reflect.Type]*instance),
+		acyclic:    make(map[reflect.Type]bool),
+		onClose:    onClose,
+	}
+}
+
+// Provide registers the constructor of its first output type,
+// it is a function like
+//
+//	func(deps ...) T
+//	func(deps ...) (T, error)
+//	func(deps ...) (T, func(), error)
+//	func(deps ...) (T, func() error, error)
+//
+// where the func() is the close function of the value.
+// A request scoped constructor may depend on *Context.
+func (c *Container) Provide(constructor interface{}, scope Scope) error {
+	fn := reflect.ValueOf(constructor)
+	if fn.Kind() != reflect.Func || fn.Type().NumOut() == 0 {
+		return fmt.Errorf("provide: %T is not a constructor", constructor)
+	}
+	typ := fn.Type()
+	p := &provider{constructor: fn, scope: scope, closeIndex: -1, errorIndex: -1}
+	for i := 1; i < typ.NumOut(); i++ {
+		out := typ.Out(i)
+		switch {
+		case i == typ.NumOut()-1 && out == errorType:
+			p.errorIndex = i
+		case i == 1 && isCloseFunc(out):
+			p.closeIndex = i
+		default:
+			return fmt.Errorf("provide: unexpected output %s of %s", out, typ)
+		}
+	} // for>
+	for i := 0; i < typ.NumIn(); i++ {
+		if typ.In(i) == contextType && scope != ScopeRequest {
+			return fmt.Errorf("provide: %s needs *Context, it must be request scoped", typ)
+		}
+	} // for>
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.providers[typ.Out(0)]; ok {
+		return fmt.Errorf("provide: %s is already provided", typ.Out(0))
+	}
+	c.providers[typ.Out(0)] = p
+	c.acyclic = make(map[reflect.Type]bool)
+	return nil
+}
+
+func isCloseFunc(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.NumIn() == 0 &&
+		(t.NumOut() == 0 || (t.NumOut() == 1 && t.Out(0) == errorType))
+}
+
+// Invoke calls fn with its parameters resolved, the singletons only,
+// the error is returned if fn returns one as its last output.
+func (c *Container) Invoke(fn interface{}) error {
+	return c.invoke(fn, nil)
+}
+
+func (c *Container) invoke(fn interface{}, rs *requestScope) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("invoke: %T is not a function", fn)
+	}
+	args, err := c.resolveArgs(v.Type(), rs)
+	if err != nil {
+		return err
+	}
+	out := v.Call(args)
+	if n := len(out); n > 0 && v.Type().Out(n-1) == errorType && !out[n-1].IsNil() {
+		return out[n-1].Interface().(error)
+	}
+	return nil
+}
+
+// Resolve sets the value pointed by target, the singletons only.
+func (c *Container) Resolve(target interface{}) error {
+	return c.resolveInto(target, nil)
+}
+
+func (c *Container) resolveInto(target interface{}, rs *requestScope) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("resolve: %T is not a pointer", target)
+	}
+	v, err := c.resolve(ptr.Type().Elem(), rs)
+	if err != nil {
+		return err
+	}
+	ptr.Elem().Set(v)
+	return nil
+}
+
+func (c *Container) resolveArgs(fn reflect.Type, rs *requestScope) ([]reflect.Value, error) {
+	args := make([]reflect.Value, fn.NumIn())
+	for i := range args {
+		v, err := c.resolve(fn.In(i), rs)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	} // for>
+	return args, nil
+}
+
+// ErrDependencyCycle is returned when the constructors depend on each other.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// resolve returns the value of the type, made once in its scope.
+// The constructors run out of the container lock, so they may use the container,
+// a value being made is waited by the others resolving it.
+func (c *Container) resolve(t reflect.Type, rs *requestScope) (reflect.Value, error) {
+	if t == contextType {
+		if rs == nil {
+			return reflect.Value{}, fmt.Errorf("resolve %s: out of a request", t)
+		}
+		return reflect.ValueOf(rs.context), nil
+	} // if>
+
+	c.lock.Lock()
+	p, ok := c.providers[t]
+	var err error
+	if ok {
+		// the cycles are found before any value is made, so the instances never wait on each other
+		err = c.checkCycle(t, nil)
+	}
+	inst := c.singletons[t]
+	if ok && p.scope == ScopeSingleton && inst == nil {
+		inst = &instance{}
+		c.singletons[t] = inst
+	}
+	c.lock.Unlock()
+	switch {
+	case !ok:
+		return reflect.Value{}, fmt.Errorf("resolve %s: no provider", t)
+	case err != nil:
+		return reflect.Value{}, err
+	case p.scope == ScopeRequest && rs == nil:
+		return reflect.Value{}, fmt.Errorf("resolve %s: it is request scoped, and out of a request", t)
+	case p.scope == ScopeRequest:
+		inst = rs.instance(t)
+	}
+
+	inst.lock.Lock()
+	defer inst.lock.Unlock()
+	if inst.done {
+		return inst.value, nil
+	}
+	v, closeFn, err := c.construct(t, p, rs)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	inst.value, inst.done = v, true
+	if p.scope == ScopeRequest {
+		rs.addCloser(closeFn)
+	} else if closeFn != nil && c.onClose != nil {
+		c.onClose(closeFn)
+	} // else>
+	return v, nil
+}
+
+// checkCycle walks the providers of the dependencies, path is the types being walked
+func (c *Container) checkCycle(t reflect.Type, path []reflect.Type) error {
+	if c.acyclic[t] {
+		return nil
+	}
+	for i, p := range path {
+		if p == t {
+			names := make([]string, 0, len(path)-i+1)
+			for _, q := range append(path[i:], t) {
+				names = append(names, q.String())
+			}
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(names, " -> "))
+		}
+	} // for>
+	if p, ok := c.providers[t]; ok {
+		typ := p.constructor.Type()
+		for i := 0; i < typ.NumIn(); i++ {
+			if err := c.checkCycle(typ.In(i), append(path, t)); err != nil {
+				return err
+			}
+		} // for>>
+	} // if>
+	c.acyclic[t] = true
+	return nil
+}
+
+// construct calls the constructor with its dependencies,
+// it returns the value and its close function, nil without one
+func (c *Container) construct(t reflect.Type, p *provider, rs *requestScope) (reflect.Value, func() error, error) {
+	// the singletons must not depend on the request scoped values
+	depScope := rs
+	if p.scope == ScopeSingleton {
+		depScope = nil
+	}
+	args, err := c.resolveArgs(p.constructor.Type(), depScope)
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	out := p.constructor.Call(args)
+	if p.errorIndex > 0 && !out[p.errorIndex].IsNil() {
+		return reflect.Value{}, nil, fmt.Errorf("resolve %s: %w", t, out[p.errorIndex].Interface().(error))
+	} // if>
+
+	var closeFn func() error
+	if p.closeIndex > 0 && !out[p.closeIndex].IsNil() {
+		switch f := out[p.closeIndex].Interface().(type) {
+		case func():
+			closeFn = func() error { f(); return nil }
+		case func() error:
+			closeFn = f
+		}
+	} // if>
+	return out[0], closeFn, nil
+}
+
+// $--- request scope ---
+// requestScope keeps the request scoped values of a request
+type requestScope struct {
+	context *Context
+	lock    sync.Mutex
+	values  map[reflect.Type]*instance
+	closers []func() error
+}
+
+func (rs *requestScope) instance(t reflect.Type) *instance {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	inst := rs.values[t]
+	if inst == nil {
+		inst = &instance{}
+		rs.values[t] = inst
+	}
+	return inst
+}
+
+func (rs *requestScope) addCloser(fn func() error) {
+	if fn == nil {
+		return
+	}
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	rs.closers = append(rs.closers, fn)
+}
+
+// close the values in the reverse order of their creation
+func (rs *requestScope) close() error {
+	rs.lock.Lock()
+	closers := rs.closers
+	rs.closers = nil
+	rs.lock.Unlock()
+
+	var err error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i](); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// $--- Tong ---
+// Container returns the dependency container of the app.
+func (t *Tong) Container() *Container {
+	t.containerOnce.Do(func() {
+		t.container = NewContainer(t.OnShutdown)
+	})
+	return t.container
+}
+
+// Provide registers the constructor in the container of the app, see Container.Provide,
+// the scope defaults to ScopeSingleton.
+func (t *Tong) Provide(constructor interface{}, scope ...Scope) error {
+	s := ScopeSingleton
+	if len(scope) > 0 {
+		s = scope[0]
+	}
+	return t.Container().Provide(constructor, s)
+}
+
+// Invoke calls fn with the singletons of the container, see Container.Invoke.
+func (t *Tong) Invoke(fn interface{}) error {
+	return t.Container().Invoke(fn)
+}
+
+// OnShutdown registers a hook run by Shutdown and Close,
+// the hooks run in the reverse order of their registration.
+func (t *Tong) OnShutdown(hook func() error) {
+	t.hookLock.Lock()
+	defer t.hookLock.Unlock()
+	t.shutdownHooks = append(t.shutdownHooks, hook)
+}
+
+// run the shutdown hooks once, the first error is returned
+func (t *Tong) runShutdownHooks() error {
+	t.hookLock.Lock()
+	hooks := t.shutdownHooks
+	t.shutdownHooks = nil
+	t.hookLock.Unlock()
+
+	var err error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	return err
+}
+
+// $--- Context ---
+// Resolve sets the value pointed by target from the container of the app,
+// the request scoped values are shared in the request.
+func (c *Context) Resolve(target interface{}) error {
+	return c.tong.Container().resolveInto(target, c.requestScope())
+}
+
+// Invoke calls fn with its parameters resolved, including the request scoped values.
+func (c *Context) Invoke(fn interface{}) error {
+	return c.tong.Container().invoke(fn, c.requestScope())
+}
+
+func (c *Context) requestScope() *requestScope {
+	if c.scope == nil {
+		c.scope = &requestScope{context: c, values: make(map[reflect.Type]*instance)}
+	}
+	return c.scope
+}
+
+// closeScope closes the request scoped values after the request
+func (c *Context) closeScope() {
+	if c.scope == nil {
+		return
+	}
+	if err := c.scope.close(); err != nil {
+		c.Logger().Warn("close the request scoped values: " + err.Error())
+	}
+	c.scope = nil
+}
@@ -0,0 +1,176 @@
+package tong_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type testConfig struct{ dsn string }
+
+type testDB struct {
+	dsn    string
+	closed bool
+}
+
+type testSession struct {
+	db        *testDB
+	requestID string
+}
+
+func TestTong_ProvideInvoke(t *testing.T) {
+	app := tongtest.NewApp()
+	events := make([]string, 0)
+	made := 0
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(app.Provide(func() testConfig { return testConfig{dsn: "mem://"} }))
+	must(app.Provide(func(cfg testConfig) (*testDB, func() error, error) {
+		made++
+		db := &testDB{dsn: cfg.dsn}
+		return db, func() error { events = append(events, "close db"); db.closed = true; return nil }, nil
+	}))
+	must(app.Provide(func(c *tong.Context, db *testDB) (*testSession, func()) {
+		s := &testSession{db: db, requestID: c.RequestID()}
+		return s, func() { events = append(events, "close session "+s.requestID) }
+	}, tong.ScopeRequest))
+	app.OnShutdown(func() error { events = append(events, "hook"); return nil })
+
+	var db *testDB
+	must(app.Invoke(func(d *testDB) { db = d }))
+	if db.dsn != "mem://" {
+		t.Errorf("db = %+v", db)
+	}
+
+	app.GET("/session", func(c *tong.Context) error {
+		var s1, s2 *testSession
+		if err := c.Resolve(&s1); err != nil {
+			return err
+		}
+		if err := c.Invoke(func(s *testSession) { s2 = s }); err != nil {
+			return err
+		}
+		if s1 != s2 || s1.db != db {
+			return errors.New("the values are not shared")
+		}
+		return c.String(http.StatusOK, s1.requestID)
+	})
+	client := tongtest.NewClient(app)
+	client.GET("/session").Header("X-Request-ID", "r1").Do(t).ExpectStatus(http.StatusOK).ExpectBody("r1")
+	client.GET("/session").Header("X-Request-ID", "r2").Do(t).ExpectStatus(http.StatusOK).ExpectBody("r2")
+	if made != 1 {
+		t.Errorf("the singleton is made %d times", made)
+	}
+
+	// the request scoped values are out of the singletons
+	if err := app.Invoke(func(s *testSession) {}); err == nil {
+		t.Error("a request scoped value is resolved out of a request")
+	}
+
+	must(app.Shutdown(context.Background()))
+	want := "close session r1,close session r2,close db,hook"
+	if got := strings.Join(events, ","); got != want || !db.closed {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+}
+
+type nodeA struct{}
+type nodeB struct{}
+
+func TestContainer_Errors(t *testing.T) {
+	c := tong.NewContainer(nil)
+	if err := c.Provide("not a function", tong.ScopeSingleton); err == nil {
+		t.Error("a string is provided")
+	}
+	if err := c.Provide(func(c *tong.Context) *nodeA { return nil }, tong.ScopeSingleton); err == nil {
+		t.Error("a singleton depends on the context")
+	}
+	_ = c.Provide(func(b *nodeB) *nodeA { return &nodeA{} }, tong.ScopeSingleton)
+	_ = c.Provide(func(a *nodeA) *nodeB { return &nodeB{} }, tong.ScopeSingleton)
+	if err := c.Provide(func() *nodeB { return nil }, tong.ScopeSingleton); err == nil {
+		t.Error("a type is provided twice")
+	}
+
+	var a *nodeA
+	err := c.Resolve(&a)
+	if !errors.Is(err, tong.ErrDependencyCycle) || !strings.Contains(err.Error(), "*tong_test.nodeA -> *tong_test.nodeB -> *tong_test.nodeA") {
+		t.Errorf("err = %v", err)
+	}
+	if err := c.Invoke(func(cfg testConfig) {}); err == nil || !strings.Contains(err.Error(), "no provider") {
+		t.Errorf("err = %v", err)
+	}
+
+	boom := errors.New("boom")
+	_ = c.Provide(func() (testConfig, error) { return testConfig{}, boom }, tong.ScopeSingleton)
+	if err := c.Invoke(func(cfg testConfig) {}); !errors.Is(err, boom) {
+		t.Errorf("err = %v", err)
+	}
+	if err := c.Invoke(func() error { return boom }); err != boom {
+		t.Errorf("err = %v", err)
+	}
+}
+
+type slowValue struct{}
+
+func TestContainer_Concurrency(t *testing.T) {
+	c := tong.NewContainer(nil)
+	_ = c.Provide(func() testConfig { return testConfig{dsn: "mem://"} }, tong.ScopeSingleton)
+	// a constructor may use the container
+	_ = c.Provide(func() *testDB {
+		var cfg testConfig
+		if err := c.Resolve(&cfg); err != nil {
+			t.Error(err)
+		}
+		return &testDB{dsn: cfg.dsn}
+	}, tong.ScopeSingleton)
+	var db *testDB
+	if err := c.Resolve(&db); err != nil || db.dsn != "mem://" {
+		t.Fatalf("db = %+v, err = %v", db, err)
+	}
+
+	// the request scoped values of the requests are made in parallel
+	entered := make(chan struct{}, 2)
+	release := make(chan struct{})
+	app := tongtest.NewApp()
+	_ = app.Provide(func(c *tong.Context) *slowValue {
+		entered <- struct{}{}
+		<-release
+		return &slowValue{}
+	}, tong.ScopeRequest)
+	app.GET("/slow", func(c *tong.Context) error {
+		var v *slowValue
+		if err := c.Resolve(&v); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, "ok")
+	})
+	client := tongtest.NewClient(app)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.GET("/slow").Do(t).ExpectStatus(http.StatusOK)
+		}()
+	}
+	defer wg.Wait()
+	defer close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-entered:
+		case <-time.After(time.Second):
+			t.Fatal("the constructors of the requests are serialized")
+		}
+	}
+}
@@ -2,20 +2,33 @@ package tong
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"github.com/ming3000/tong/common"
+	"github.com/ming3000/tong/i18n"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // Context is context for every goroutine
 type Context struct {
+	tong         *Tong
 	request      *http.Request
 	response     *Response
 	path         string
 	handler      HandlerFunc
 	logger       *common.Logger
 	requestCache common.Cache
+	requestID    string
+	paramNames   []string
+	paramValues  []string
+	scope        *requestScope
+	localizer    *i18n.Localizer
+	// set on the contexts asking a typed handler for its metadata
+	describe *handlerMeta
 }
 
 // $--- utils ---
@@ -26,6 +39,10 @@ func (c *Context) Reset(r *http.Request, w http.ResponseWriter, logger *common.L
 	c.handler = NotFoundHandler
 	c.logger = logger
 	c.requestCache = cache
+	c.requestID = ""
+	c.paramNames, c.paramValues = c.paramNames[:0], c.paramValues[:0]
+	c.scope = nil
+	c.localizer = nil
 }
 
 func (c *Context) Redirect(code int, url string) error {
@@ -46,10 +63,26 @@ func (c *Context) Response() *Response {
 	return c.response
 }
 
+// Path returns the path of the matched route, e.g. /users/:id.
 func (c *Context) Path() string {
 	return c.path
 }
 
+// Param returns the value of the path param, e.g. id of /users/:id.
+func (c *Context) Param(name string) string {
+	for i, n := range c.paramNames {
+		if n == name {
+			return c.paramValues[i]
+		}
+	}
+	return ""
+}
+
+// ParamNames returns the names of the path params.
+func (c *Context) ParamNames() []string {
+	return c.paramNames
+}
+
 func (c *Context) Handler() HandlerFunc {
 	return c.handler
 }
@@ -58,10 +91,55 @@ func (c *Context) RequestCache() common.Cache {
 	return c.requestCache
 }
 
+// Logger returns the logger of the request,
+// it carries the request id, method, client ip and route of the request.
 func (c *Context) Logger() *common.Logger {
 	return c.logger
 }
 
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+// RealIP returns the client ip, the headers set by proxies are respected
+// only if the request comes from a trusted proxy, see Tong.SetTrustedProxies.
+func (c *Context) RealIP() string {
+	ip, _, err := net.SplitHostPort(c.request.RemoteAddr)
+	if err != nil {
+		ip = c.request.RemoteAddr
+	} // if>
+	if c.tong == nil || !c.tong.trustedProxy(ip) {
+		return ip
+	} // if>
+	// the client is the nearest hop not being a trusted proxy
+	if forwarded := c.request.Header.Get(common.HeaderXForwardedFor); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(hops[i])
+			if !c.tong.trustedProxy(ip) {
+				break
+			} // if>>
+		} // for>>
+		return ip
+	} // if>
+	if realIP := c.request.Header.Get(common.HeaderXRealIP); realIP != "" {
+		return strings.TrimSpace(realIP)
+	} // if>
+	return ip
+}
+
+// $--- Setter ---
+// SetLogger replaces the logger of the request.
+func (c *Context) SetLogger(logger *common.Logger) {
+	c.logger = logger
+}
+
+// AddLogFields adds the key-value pairs to the logger of the request,
+// e.g. the user id after authentication.
+func (c *Context) AddLogFields(fields ...interface{}) {
+	c.logger = c.logger.With(fields...)
+}
+
 // $--- Writer ---
 func (c *Context) WriteContentType(value string) {
 	head := c.response.Header()
@@ -71,8 +149,9 @@ func (c *Context) WriteContentType(value string) {
 }
 
 func (c *Context) Blob(code int, contentType string, data []byte) error {
-	c.response.WriteHeader(code)
+	// the headers must be set before they are written
 	c.WriteContentType(contentType)
+	c.response.WriteHeader(code)
 	_, err := c.response.Write(data)
 	return err
 }
@@ -91,6 +170,79 @@ func (c *Context) String(code int, value string) error {
 	return c.Blob(code, common.MIMETextPlainCharsetUTF8, []byte(value))
 }
 
+func (c *Context) XML(code int, value interface{}) error {
+	data, err := xml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, common.MIMEApplicationXMLCharsetUTF8, append([]byte(xml.Header), data...))
+}
+
+// Render writes the value in the format accepted by the client:
+// JSON by default, XML, or text for a string, an error or a fmt.Stringer.
+func (c *Context) Render(code int, value interface{}) error {
+	switch c.Negotiate(common.MIMEApplicationJSON, common.MIMEApplicationXML, common.MIMETextXML, common.MIMETextPlain) {
+	case common.MIMEApplicationXML, common.MIMETextXML:
+		return c.XML(code, value)
+	case common.MIMETextPlain:
+		switch v := value.(type) {
+		case string:
+			return c.String(code, v)
+		case error:
+			return c.String(code, v.Error())
+		case fmt.Stringer:
+			return c.String(code, v.String())
+		}
+	}
+	return c.Json(code, value, "")
+}
+
+// Negotiate returns the offer preferred by the Accept header,
+// the first offer if the header is missing or nothing is acceptable.
+func (c *Context) Negotiate(offers ...string) string {
+	type acceptRange struct {
+		mime string
+		q    float64
+	}
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(c.request.Header.Get(common.HeaderAccept), ",") {
+		params := strings.Split(part, ";")
+		r := acceptRange{mime: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				r.q, _ = strconv.ParseFloat(kv[1], 64)
+			}
+		} // for>>
+		if r.mime != "" {
+			ranges = append(ranges, r)
+		}
+	} // for>
+
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		// the quality of the most specific range matching the offer
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.mime == offer:
+				s = 2
+			case strings.HasSuffix(r.mime, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(r.mime, "*")):
+				s = 1
+			case r.mime == "*/*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		} // for>>
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	} // for>
+	return best
+}
+
 // $--- Query Reader ---
 func (c *Context) QueryInt(key string, defaultValue int) int {
 	value := c.request.URL.Query().Get(key)
@@ -0,0 +1,85 @@
+package tong_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestContext_Query(t *testing.T) {
+	app := tongtest.NewApp()
+	c, _ := tongtest.NewContext(app, tongtest.NewRequest(http.MethodGet, "/?age=30&rate=0.5&name=tom&bad=x", nil))
+
+	if c.QueryInt("age", 3) != 30 || c.QueryInt("bad", 3) != 3 || c.QueryInt("missing", 3) != 3 {
+		t.Fatal("unexpected QueryInt")
+	}
+	if c.QueryFloat("rate", 1) != 0.5 || c.QueryFloat("bad", 1) != 1 {
+		t.Fatal("unexpected QueryFloat")
+	}
+	if c.QueryString("name", "nobody") != "tom" || c.QueryString("missing", "nobody") != "nobody" {
+		t.Fatal("unexpected QueryString")
+	}
+}
+
+func TestContext_Json(t *testing.T) {
+	app := tongtest.NewApp()
+	c, w := tongtest.NewContext(app, tongtest.NewRequest(http.MethodGet, "/", nil))
+	if err := c.Json(http.StatusAccepted, map[string]int{"a": 1}, "  "); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusAccepted || w.Body.String() != "{\n  \"a\": 1\n}\n" {
+		t.Fatal("unexpected response", w.Code, w.Body.String())
+	}
+	if w.Result().Header.Get("Content-Type") != "application/json; charset=UTF-8" {
+		t.Fatal("unexpected content type", w.Result().Header)
+	}
+}
+
+func TestContext_Redirect(t *testing.T) {
+	app := tongtest.NewApp()
+	c, w := tongtest.NewContext(app, tongtest.NewRequest(http.MethodGet, "/", nil))
+	if err := c.Redirect(http.StatusOK, "/login"); err == nil {
+		t.Fatal("redirect with a non 3xx code should fail")
+	}
+	if err := c.Redirect(http.StatusFound, "/login"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusFound || w.Result().Header.Get("Location") != "/login" {
+		t.Fatal("unexpected redirect", w.Code, w.Result().Header)
+	}
+}
+
+func TestContext_RealIP(t *testing.T) {
+	app := tongtest.NewApp()
+	r := tongtest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	c, _ := tongtest.NewContext(app, r)
+	if c.RealIP() != "10.0.0.1" {
+		t.Fatal("unexpected remote ip", c.RealIP())
+	}
+	// the headers are ignored from an untrusted peer
+	r.Header.Set("X-Real-IP", "10.0.0.2")
+	if c.RealIP() != "10.0.0.1" {
+		t.Fatal("headers of an untrusted peer should be ignored", c.RealIP())
+	}
+
+	if err := app.SetTrustedProxies("10.0.0.1", "192.168.0.0/16"); err != nil {
+		t.Fatal(err)
+	}
+	if c.RealIP() != "10.0.0.2" {
+		t.Fatal("unexpected real ip", c.RealIP())
+	}
+	// the spoofed hops before the trusted proxies are skipped
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.3, 192.168.1.1")
+	if c.RealIP() != "10.0.0.3" {
+		t.Fatal("unexpected forwarded ip", c.RealIP())
+	}
+	r.Header.Set("X-Forwarded-For", "192.168.1.2, 192.168.1.1")
+	if c.RealIP() != "192.168.1.2" {
+		t.Fatal("the first hop should be the client when all are trusted", c.RealIP())
+	}
+	if err := app.SetTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Fatal("invalid proxy should be rejected")
+	}
+}
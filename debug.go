@@ -0,0 +1,189 @@
+package tong
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/ming3000/tong/common"
+	"html/template"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// debugInfo is the content of the debug page
+type debugInfo struct {
+	Status     int                 `json:"status"`
+	Errors     []debugError        `json:"errors"`
+	Stack      []debugFrame        `json:"stack,omitempty"`
+	Route      *debugRoute         `json:"route,omitempty"`
+	Params     map[string]string   `json:"params,omitempty"`
+	Headers    map[string][]string `json:"headers"`
+	Query      map[string][]string `json:"query,omitempty"`
+	Form       map[string][]string `json:"form,omitempty"`
+	Middleware []string            `json:"middleware"`
+}
+
+// debugError is an error of the chain
+type debugError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type debugFrame struct {
+	Function string      `json:"function"`
+	File     string      `json:"file"`
+	Line     int         `json:"line"`
+	Source   []debugLine `json:"source,omitempty"`
+}
+
+type debugLine struct {
+	Number  int    `json:"number"`
+	Text    string `json:"text"`
+	Current bool   `json:"current,omitempty"`
+}
+
+type debugRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+}
+
+// the lines of the source around the line of a frame
+const debugSourceLines = 5
+
+// renderDebug writes the debug page of the error, in HTML or JSON by the Accept header,
+// it returns false if the client accepts neither of them explicitly, e.g. curl.
+func renderDebug(c *Context, code int, err error) bool {
+	accept := c.Request().Header.Get(common.HeaderAccept)
+	format := c.Negotiate(common.MIMETextHTML, common.MIMEApplicationJSON)
+	if !strings.Contains(accept, format) {
+		return false
+	}
+	info := newDebugInfo(c, code, err)
+	if format == common.MIMEApplicationJSON {
+		he := AsHTTPError(err)
+		message := he.Message
+		if he.Internal == err {
+			message = debugRedactor(c).String(err.Error())
+		}
+		_ = c.Json(code, map[string]interface{}{"message": message, "details": he.Details, "debug": info}, "")
+		return true
+	}
+
+	c.WriteContentType(common.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(code)
+	if renderErr := debugPageTemplate.Execute(c.Response(), info); renderErr != nil {
+		c.Logger().Warn("render the debug page: " + renderErr.Error())
+	}
+	return true
+}
+
+// NewDebugRedactor returns the default redactor of the debug page, masking the credential headers,
+// the password, token and secret fields, and the card and phone numbers.
+func NewDebugRedactor() *common.Redactor {
+	return common.NewRedactor().
+		Headers("Proxy-Authorization", "X-Api-Key", "X-Auth-Token", "X-Csrf-Token").
+		Fields("password", "token", "secret").
+		Patterns(common.CardNumberPattern, common.PhoneNumberPattern)
+}
+
+// the redactor of the app, or the default one
+func debugRedactor(c *Context) *common.Redactor {
+	if c.tong != nil && c.tong.DebugRedactor != nil {
+		return c.tong.DebugRedactor
+	}
+	return NewDebugRedactor()
+}
+
+func newDebugInfo(c *Context, code int, err error) *debugInfo {
+	r := c.Request()
+	redactor := debugRedactor(c)
+	info := &debugInfo{
+		Status:     code,
+		Headers:    redactor.Header(r.Header),
+		Query:      redactValues(redactor, r.URL.Query()),
+		Form:       redactValues(redactor, r.PostForm),
+		Middleware: make([]string, 0),
+	}
+
+	// the chain of the errors, and the first stack of them
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		info.Errors = append(info.Errors, debugError{Type: fmt.Sprintf("%T", e), Message: redactor.String(e.Error())})
+		if tracer, ok := e.(StackTracer); ok && info.Stack == nil {
+			for _, f := range stackFrames(tracer.StackTrace()) {
+				info.Stack = append(info.Stack, debugFrame{Function: f.Function, File: f.File, Line: f.Line, Source: sourceLines(f.File, f.Line)})
+			}
+		} // if>>
+	} // for>
+
+	if len(c.paramNames) > 0 {
+		info.Params = make(map[string]string, len(c.paramNames))
+		for i, name := range c.paramNames {
+			info.Params[name] = fmt.Sprint(redactor.Field(name, c.paramValues[i]))
+		}
+	} // if>
+	t := c.tong
+	if t == nil {
+		return info
+	}
+	for _, m := range append(append([]MiddlewareFunc{}, t.sysMiddleware...), t.customerMiddleware...) {
+		info.Middleware = append(info.Middleware, funcName(m))
+	}
+	if route, ok := t.router.routes[r.Method+c.Path()]; ok {
+		info.Route = &debugRoute{Method: route.Method, Path: route.Path, Name: route.Name}
+		info.Middleware = append(info.Middleware, route.Middleware...)
+	}
+	return info
+}
+
+func redactValues(redactor *common.Redactor, values map[string][]string) map[string][]string {
+	if len(values) == 0 {
+		return nil
+	}
+	ret := make(map[string][]string, len(values))
+	for key, vs := range values {
+		masked := make([]string, len(vs))
+		for i, v := range vs {
+			masked[i] = fmt.Sprint(redactor.Field(key, v))
+		}
+		ret[key] = masked
+	} // for>
+	return ret
+}
+
+// the closures of a function are named like pkg.Func.func1
+var closureSuffix = regexp.MustCompile(`(\.func\d+|-fm)+$`)
+
+// funcName returns the name of the function, without the package path and the closures,
+// e.g. middleware.LoggerWithConfig
+func funcName(fn interface{}) string {
+	name := closureSuffix.ReplaceAllString(handlerName(fn), "")
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// the lines around the line of the file, or nil if it is not readable
+func sourceLines(file string, line int) []debugLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	lines := make([]debugLine, 0, 2*debugSourceLines+1)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+debugSourceLines; n++ {
+		if n >= line-debugSourceLines {
+			lines = append(lines, debugLine{Number: n, Text: scanner.Text(), Current: n == line})
+		}
+	} // for>
+	return lines
+}
+
+//go:embed debug.html
+var debugPage string
+
+var debugPageTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"statusText": http.StatusText,
+}).Parse(debugPage))
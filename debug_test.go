@@ -0,0 +1,86 @@
+package tong_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestTong_DebugPage(t *testing.T) {
+	if tong.New().Debug {
+		t.Fatal("the debug page should be opted in")
+	}
+	app := tongtest.NewApp()
+	app.Debug = true
+	cause := errors.New("connection refused")
+	app.POST("/orders", func(c *tong.Context) error {
+		if err := c.Request().ParseForm(); err != nil {
+			return err
+		}
+		return tong.NewHTTPError(http.StatusServiceUnavailable, "try later").SetInternal(fmt.Errorf("dial db: %w", cause))
+	}, func(next tong.HandlerFunc) tong.HandlerFunc {
+		return next
+	})
+	app.GET("/orders", func(c *tong.Context) error {
+		return tong.NewHTTPError(http.StatusBadRequest, "bad page")
+	})
+	client := tongtest.NewClient(app)
+
+	var body struct {
+		Message string `json:"message"`
+		Debug   struct {
+			Status     int
+			Errors     []struct{ Type, Message string }
+			Stack      []struct{ Function string }
+			Headers    map[string][]string
+			Form       map[string][]string
+			Query      map[string][]string
+			Middleware []string
+		}
+	}
+	// not a literal, as the source of the test is on the page
+	secret := "s3" + "cr3t"
+	resp := client.POST("/orders").Query("page", "2").Header("Accept", "application/json").
+		Header("Authorization", "Bearer "+secret).Header("Cookie", "id="+secret).Header("X-Api-Key", secret).
+		Form(url.Values{"item": {"book"}, "password": {secret}}).Do(t).
+		ExpectStatus(http.StatusServiceUnavailable).DecodeJSON(&body)
+	debug := body.Debug
+	if strings.Contains(resp.Body(), secret) || debug.Headers["Authorization"][0] != "******" {
+		t.Errorf("the credentials are shown: %s", resp.Body())
+	}
+	if body.Message != "try later" || debug.Status != http.StatusServiceUnavailable || len(debug.Errors) != 3 ||
+		debug.Errors[1].Message != "dial db: connection refused" || debug.Errors[2].Type != "*errors.errorString" {
+		t.Errorf("body = %+v", body)
+	}
+	if len(debug.Stack) == 0 || debug.Stack[0].Function != "github.com/ming3000/tong_test.TestTong_DebugPage.func1" {
+		t.Errorf("stack = %+v", debug.Stack)
+	}
+	if debug.Form["item"][0] != "book" || debug.Form["password"][0] != "******" || debug.Query["page"][0] != "2" {
+		t.Errorf("form = %v, query = %v", debug.Form, debug.Query)
+	}
+	if len(debug.Middleware) != 1 || debug.Middleware[0] != "tong_test.TestTong_DebugPage" {
+		t.Errorf("middleware = %v", debug.Middleware)
+	}
+
+	// the client errors are not debugged
+	client.GET("/orders").Header("Accept", "application/json").Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectJSON(map[string]string{"message": "bad page"})
+	// nor the HEAD requests
+	client.NewRequest(http.MethodHead, "/orders").Header("Accept", "text/html").Do(t).
+		ExpectStatus(http.StatusInternalServerError).ExpectBody("")
+}
+
+func TestHTTPError_Stack(t *testing.T) {
+	if stack := tong.NewHTTPError(http.StatusNotFound).StackTrace(); stack != nil {
+		t.Errorf("a client error captures the stack %v", stack)
+	}
+	if stack := tong.NewHTTPError(http.StatusBadGateway).StackTrace(); len(stack) == 0 {
+		t.Error("a server error misses the stack")
+	}
+}
@@ -0,0 +1,153 @@
+package tong
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ming3000/tong/common"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// the environment of the app run by `tong dev`
+const (
+	// ListenFDEnv is the file descriptor of the listener handed to the app,
+	// the app serves on it instead of listening, and shuts down gracefully on SIGTERM
+	ListenFDEnv = "TONG_LISTEN_FD"
+	// LiveReloadEnv is the URL of the live reload events,
+	// the HTML responses get a script reloading the page on them, with app.Debug
+	LiveReloadEnv = "TONG_LIVERELOAD"
+)
+
+// handoffTimeout is the time the requests are given to finish on SIGTERM
+const handoffTimeout = 10 * time.Second
+
+// listen returns the listener handed by ListenFDEnv with its handoff, or a new one of the address
+func (t *Tong) listen(address string) (net.Listener, *handoff, error) {
+	value := os.Getenv(ListenFDEnv)
+	if value == "" {
+		l, err := net.Listen("tcp", address)
+		return l, nil, err
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", ListenFDEnv, err)
+	}
+	f := os.NewFile(uintptr(fd), "listener")
+	l, err := net.FileListener(f)
+	// the listener has its own copy of the fd
+	_ = f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", ListenFDEnv, err)
+	}
+
+	// the next process takes over the listener, this one finishes its requests
+	h := &handoff{
+		signals:    make(chan os.Signal, 1),
+		terminated: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	signal.Notify(h.signals, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-h.signals; !ok {
+			return
+		}
+		close(h.terminated)
+		defer close(h.done)
+		ctx, cancel := context.WithTimeout(context.Background(), handoffTimeout)
+		defer cancel()
+		if err := t.Shutdown(ctx); err != nil {
+			t.Logger.Warn("shutdown on SIGTERM: " + err.Error())
+		}
+	}()
+	return l, h, nil
+}
+
+// handoff shuts the app down on SIGTERM, when the listener is handed over by `tong dev`
+type handoff struct {
+	signals    chan os.Signal
+	terminated chan struct{}
+	done       chan struct{}
+}
+
+// wait blocks until the shutdown on SIGTERM has returned,
+// or stops watching SIGTERM if the server is stopped otherwise.
+func (h *handoff) wait() {
+	if h == nil {
+		return
+	}
+	select {
+	case <-h.terminated:
+		<-h.done
+	default:
+		signal.Stop(h.signals)
+		close(h.signals)
+	}
+}
+
+// $--- live reload ---
+// EnableLiveReload injects a script into the HTML responses with app.Debug,
+// the script reloads the page on the "reload" events of the URL, e.g. sent by `tong dev`.
+func (t *Tong) EnableLiveReload(url string) {
+	t.liveReloadScript = []byte(fmt.Sprintf(`<script>(function(){var s=new EventSource(%q);`+
+		`s.onmessage=function(e){if(e.data==="reload"){s.close();location.reload();}};})();</script>`, url))
+}
+
+// liveReloadWriter injects the script into the HTML responses, before </body>
+type liveReloadWriter struct {
+	http.ResponseWriter
+	script   []byte
+	html     bool
+	injected bool
+}
+
+func (w *liveReloadWriter) WriteHeader(code int) {
+	if strings.HasPrefix(w.Header().Get(common.HeaderContentType), common.MIMETextHTML) {
+		w.html = true
+		// the length is changed by the script
+		w.Header().Del(common.HeaderContentLength)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *liveReloadWriter) Write(data []byte) (int, error) {
+	if !w.html || w.injected {
+		return w.ResponseWriter.Write(data)
+	}
+	i := bytes.LastIndex(bytes.ToLower(data), []byte("</body>"))
+	if i < 0 {
+		return w.ResponseWriter.Write(data)
+	}
+	w.injected = true
+	out := make([]byte, 0, len(data)+len(w.script))
+	out = append(append(append(out, data[:i]...), w.script...), data[i:]...)
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// finish appends the script to the HTML responses without </body>
+func (w *liveReloadWriter) finish() {
+	if w.html && !w.injected {
+		w.injected = true
+		_, _ = w.ResponseWriter.Write(w.script)
+	}
+}
+
+func (w *liveReloadWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *liveReloadWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return (&Response{Writer: w.ResponseWriter}).Hijack()
+}
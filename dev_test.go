@@ -0,0 +1,165 @@
+package tong_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestTong_LiveReload(t *testing.T) {
+	app := tongtest.NewApp()
+	app.Debug = true
+	app.EnableLiveReload("http://127.0.0.1:35729/livereload")
+	app.GET("/page", func(c *tong.Context) error {
+		c.Response().Header().Set(common.HeaderContentLength, "28")
+		return c.Blob(http.StatusOK, common.MIMETextHTMLCharsetUTF8, []byte("<html><BODY>hi</BODY></html>"))
+	})
+	app.GET("/fragment", func(c *tong.Context) error {
+		return c.Blob(http.StatusOK, common.MIMETextHTMLCharsetUTF8, []byte("<p>hi</p>"))
+	})
+	app.GET("/text", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "</body>")
+	})
+	client := tongtest.NewClient(app)
+
+	script := `new EventSource("http://127.0.0.1:35729/livereload")`
+	resp := client.GET("/page").Do(t).ExpectStatus(http.StatusOK).ExpectBodyContains(script)
+	if resp.Header().Get(common.HeaderContentLength) != "" {
+		t.Error("the length is kept")
+	}
+	if body := resp.Body(); !strings.HasPrefix(body, "<html><BODY>hi<script>") || !strings.HasSuffix(body, "</script></BODY></html>") {
+		t.Errorf("body = %s", body)
+	}
+	client.GET("/fragment").Do(t).ExpectBodyContains("<p>hi</p><script>")
+	client.GET("/text").Do(t).ExpectBody("</body>")
+
+	// only with app.Debug
+	app.Debug = false
+	client.GET("/page").Do(t).ExpectBody("<html><BODY>hi</BODY></html>")
+}
+
+func TestTong_ListenerHandoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_ = l.Close()
+
+	t.Setenv(tong.ListenFDEnv, strconv.Itoa(int(f.Fd())))
+	app := tongtest.NewApp()
+	app.GET("/ping", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "pong")
+	})
+	done := make(chan error, 1)
+	go func() { done <- app.Start("127.0.0.1:1") }()
+	defer app.Close()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + l.Addr().String() + "/ping"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	} // for>
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "pong" {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestTong_HandoffGraceful(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_ = l.Close()
+
+	t.Setenv(tong.ListenFDEnv, strconv.Itoa(int(f.Fd())))
+	app := tongtest.NewApp()
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	app.GET("/slow", func(c *tong.Context) error {
+		entered <- struct{}{}
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+	app.GET("/ping", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "pong")
+	})
+	served := make(chan error, 1)
+	go func() { served <- app.Start("127.0.0.1:1") }()
+
+	// no idle connection is left to delay the shutdown
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	base := "http://" + l.Addr().String()
+	for i := 0; i < 50; i++ {
+		var resp *http.Response
+		if resp, err = client.Get(base + "/ping"); err == nil {
+			_ = resp.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	} // for>
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a request is in flight when SIGTERM arrives
+	body := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(base + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		body <- string(data)
+	}()
+	<-entered
+	process, _ := os.FindProcess(os.Getpid())
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-served:
+		t.Fatal("the server returned before the request finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("body = %s", got)
+	}
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the server should return after the shutdown")
+	}
+}
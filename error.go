@@ -0,0 +1,133 @@
+package tong
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// HTTPError is an error with the status code and the message sent to the client,
+// the internal error is logged but not sent.
+type HTTPError struct {
+	Code     int         `json:"-" xml:"-"`
+	Message  string      `json:"message" xml:"message"`
+	Details  interface{} `json:"details,omitempty" xml:"-"`
+	Internal error       `json:"-" xml:"-"`
+	// where the server error is made, for the debug page
+	stack []uintptr
+}
+
+// NewHTTPError returns an HTTPError of the code, the message defaults to the status text.
+// The stack is captured for the server errors only, as the debug page never shows the client errors.
+func NewHTTPError(code int, message ...interface{}) *HTTPError {
+	he := &HTTPError{Code: code, Message: http.StatusText(code)}
+	if code >= http.StatusInternalServerError {
+		he.stack = callers(3)
+	}
+	if len(message) > 0 {
+		he.Message = fmt.Sprint(message...)
+	}
+	return he
+}
+
+func (he *HTTPError) Error() string {
+	if he.Internal == nil {
+		return fmt.Sprintf("code=%d, message=%s", he.Code, he.Message)
+	}
+	return fmt.Sprintf("code=%d, message=%s, internal=%v", he.Code, he.Message, he.Internal)
+}
+
+func (he *HTTPError) Unwrap() error {
+	return he.Internal
+}
+
+// StackTrace returns the program counters where the error is made, nil for a client error.
+func (he *HTTPError) StackTrace() []uintptr {
+	return he.stack
+}
+
+// SetInternal sets the cause of the error.
+func (he *HTTPError) SetInternal(err error) *HTTPError {
+	he.Internal = err
+	return he
+}
+
+// SetDetails sets the details sent with the message, e.g. the validation errors.
+func (he *HTTPError) SetDetails(details interface{}) *HTTPError {
+	he.Details = details
+	return he
+}
+
+// AsHTTPError returns the HTTPError in the chain of err,
+// or an internal server error hiding err.
+func AsHTTPError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	return NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+}
+
+// StackTracer is implemented by the errors recording where they are made,
+// the stack is shown by the debug page.
+type StackTracer interface {
+	StackTrace() []uintptr
+}
+
+// PanicError is a recovered panic, with the stack where it panics.
+type PanicError struct {
+	Value interface{}
+	stack []uintptr
+}
+
+// NewPanicError returns a PanicError of the recovered value,
+// it is called in the deferred function recovering the panic.
+func NewPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, stack: callers(4)}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
+}
+
+// Unwrap returns the value if it is an error.
+func (pe *PanicError) Unwrap() error {
+	err, _ := pe.Value.(error)
+	return err
+}
+
+func (pe *PanicError) StackTrace() []uintptr {
+	return pe.stack
+}
+
+// Stack returns the stack as the lines of "function file:line".
+func (pe *PanicError) Stack() string {
+	lines := make([]string, 0, len(pe.stack))
+	for _, f := range stackFrames(pe.stack) {
+		lines = append(lines, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// the program counters of the callers, skipping runtime.Callers and the others of skip
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(skip, pcs)]
+}
+
+// the frames of the stack, without the runtime
+func stackFrames(pcs []uintptr) []runtime.Frame {
+	result := make([]runtime.Frame, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !strings.HasPrefix(f.Function, "runtime.") {
+			result = append(result, f)
+		}
+		if !more {
+			return result
+		}
+	} // for>
+}
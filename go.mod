@@ -1,5 +1,9 @@
 module github.com/ming3000/tong
 
-go 1.14
+go 1.21
 
-require gopkg.in/natefinch/lumberjack.v2 v2.0.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
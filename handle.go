@@ -0,0 +1,93 @@
+package tong
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// StatusCoder is a response choosing its status code, it defaults to StatusOK.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Handle adapts a typed function to a HandlerFunc:
+// the request is bound and validated as Context.Bind does,
+// the response is rendered as Context.Render does,
+// and the errors are HTTPErrors, the others are internal server errors.
+//
+//	app.POST("/users", tong.Handle(func(c *tong.Context, req CreateUser) (*User, error) {
+//		...
+//	}))
+//
+// The request and response types are the metadata of the route in the OpenAPI document.
+func Handle[Req any, Resp any](fn func(c *Context, req Req) (Resp, error)) HandlerFunc {
+	var zeroReq Req
+	var zeroResp Resp
+	meta := handlerMeta{
+		name:     handlerName(fn),
+		request:  reflect.TypeOf(zeroReq),
+		response: reflect.TypeOf(&zeroResp).Elem(),
+	}
+	return func(c *Context) error {
+		// the route asks for the metadata, see handlerMetaOf
+		if c.describe != nil {
+			*c.describe = meta
+			return nil
+		}
+
+		var req Req
+		target := reflect.ValueOf(&req)
+		if t := reflect.TypeOf(req); t != nil && t.Kind() == reflect.Ptr {
+			// bind into a new value for a pointer type
+			target.Elem().Set(reflect.New(t.Elem()))
+			target = target.Elem()
+		}
+		if err := c.Bind(target.Interface()); err != nil {
+			return AsHTTPError(err)
+		}
+
+		resp, err := fn(c, req)
+		if err != nil {
+			return AsHTTPError(err)
+		}
+		if c.Response().IfHeaderBeenSet {
+			// the function wrote the response itself
+			return nil
+		}
+		if v := reflect.ValueOf(resp); !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			c.Response().WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		code := http.StatusOK
+		if sc, ok := interface{}(resp).(StatusCoder); ok {
+			code = sc.StatusCode()
+		}
+		if code == http.StatusNoContent {
+			c.Response().WriteHeader(code)
+			return nil
+		}
+		return c.Render(code, resp)
+	}
+}
+
+// $--- handler metadata ---
+// handlerMeta is the metadata of a handler made by Handle
+type handlerMeta struct {
+	name     string
+	request  reflect.Type
+	response reflect.Type
+}
+
+// the name of the closures made by Handle, it is the same for all the type arguments
+var typedHandlerName = handlerName(Handle[struct{}, struct{}](nil))
+
+// handlerMetaOf asks a handler made by Handle for its metadata,
+// the other handlers are never called.
+func handlerMetaOf(h HandlerFunc) (handlerMeta, bool) {
+	if h == nil || handlerName(h) != typedHandlerName {
+		return handlerMeta{}, false
+	}
+	var meta handlerMeta
+	_ = h(&Context{describe: &meta})
+	return meta, true
+}
@@ -0,0 +1,105 @@
+package tong_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type createUserRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type created struct {
+	User
+}
+
+func (created) StatusCode() int {
+	return http.StatusCreated
+}
+
+func TestHandle(t *testing.T) {
+	app := tongtest.NewApp()
+	app.POST("/users", tong.Handle(func(c *tong.Context, req createUserRequest) (created, error) {
+		return created{User{ID: 1, Name: req.Name}}, nil
+	}))
+	app.GET("/users", tong.Handle(func(c *tong.Context, req *Paging) ([]User, error) {
+		return []User{{ID: 1, Name: "tom"}}, nil
+	}))
+	app.GET("/me", tong.Handle(func(c *tong.Context, req struct{}) (*User, error) {
+		return nil, nil
+	}))
+	app.GET("/fail", tong.Handle(func(c *tong.Context, req struct{}) (*User, error) {
+		if c.QueryString("kind", "") == "http" {
+			return nil, tong.NewHTTPError(http.StatusConflict, "taken")
+		}
+		return nil, errors.New("database password is wrong")
+	}))
+	client := tongtest.NewClient(app)
+
+	var user User
+	client.POST("/users").JSON(map[string]string{"name": "tom"}).Do(t).
+		ExpectStatus(http.StatusCreated).DecodeJSON(&user)
+	if user.ID != 1 || user.Name != "tom" {
+		t.Errorf("user = %+v", user)
+	}
+	client.POST("/users").JSON(map[string]string{}).Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectJSON(map[string]interface{}{
+			"message": "validation failed",
+			"details": []map[string]string{{"field": "name", "rule": "required", "message": "is required"}},
+		})
+
+	client.GET("/users").Do(t).ExpectStatus(http.StatusOK).ExpectBodyContains(`"name":"tom"`)
+	client.GET("/me").Do(t).ExpectStatus(http.StatusNoContent).ExpectBody("")
+	client.GET("/users").Header("Accept", "application/xml").Do(t).
+		ExpectHeader("Content-Type", "application/xml; charset=UTF-8").ExpectBodyContains("<Name>tom</Name>")
+
+	client.GET("/fail").Query("kind", "http").Do(t).ExpectStatus(http.StatusConflict).ExpectJSON(map[string]string{"message": "taken"})
+	// the internal errors are not sent
+	client.GET("/fail").Header("Accept", "text/plain").Do(t).
+		ExpectStatus(http.StatusInternalServerError).ExpectBody("Internal Server Error")
+}
+
+func TestHandle_RouteInfo(t *testing.T) {
+	app := tongtest.NewApp()
+	app.POST("/users", tong.Handle(createUser)).Tag("users")
+	routes := app.Routes()
+	if len(routes) != 1 || routes[0].Name != "github.com/ming3000/tong_test.createUser" {
+		t.Fatalf("routes = %+v", routes)
+	}
+
+	op := openAPIJSON(t, app)
+	if ref := lookup(t, op, "paths", "/users", "post", "requestBody", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/createUserRequest" {
+		t.Errorf("request = %v", ref)
+	}
+	if ref := lookup(t, op, "paths", "/users", "post", "responses", "200", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/User" {
+		t.Errorf("response = %v", ref)
+	}
+}
+
+func createUser(c *tong.Context, req createUserRequest) (*User, error) {
+	return &User{Name: req.Name}, nil
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "text/plain"}
+	for accept, want := range map[string]string{
+		"":       "application/json",
+		"*/*":    "application/json",
+		"text/*": "text/plain",
+		"application/xml, application/json;q=0.9": "application/xml",
+		"application/json;q=0.5, application/xml": "application/xml",
+		"text/html":             "application/json",
+		"text/plain, */*;q=0.1": "text/plain",
+	} {
+		r := tongtest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		c, _ := tongtest.NewContext(tongtest.NewApp(), r)
+		if got := c.Negotiate(offers...); got != want {
+			t.Errorf("Accept %q: %s, want %s", accept, got, want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package i18n translates the messages of the catalogs loaded from JSON, YAML or TOML files,
+// a catalog is named by its locale, e.g. zh-CN.yaml:
+//
+//	greeting: 你好，{name}
+//	cart:
+//	  items:
+//	    other: 购物车里有 {count} 件商品
+//
+// and en.yaml with the plural forms of the CLDR categories:
+//
+//	cart:
+//	  items:
+//	    one: "{count} item in the cart"
+//	    other: "{count} items in the cart"
+//
+//	l := bundle.Localizer("zh-CN")
+//	l.T("cart.items", "count", 3)
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// builtin are the translations of the validation and the HTTP error messages
+//
+//go:embed locales/*.yaml
+var builtin embed.FS
+
+// message is a text, or the texts of the plural categories
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+// catalog is the messages of a locale, keyed by their dotted names
+type catalog map[string]*message
+
+// Bundle keeps the catalogs of the locales, it is safe for concurrent use.
+type Bundle struct {
+	lock          sync.RWMutex
+	defaultLocale string
+	// the locales as they are loaded, keyed by their normalized tags
+	locales  map[string]string
+	catalogs map[string]catalog
+	builtin  map[string]catalog
+}
+
+// NewBundle returns a new Bundle instance, the missing messages fall back to the default locale.
+func NewBundle(defaultLocale string) *Bundle {
+	b := &Bundle{
+		defaultLocale: defaultLocale,
+		locales:       make(map[string]string),
+		catalogs:      make(map[string]catalog),
+		builtin:       make(map[string]catalog),
+	}
+	files, _ := fs.ReadDir(builtin, "locales")
+	for _, f := range files {
+		data, _ := builtin.ReadFile("locales/" + f.Name())
+		var messages map[string]interface{}
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			panic(err)
+		}
+		c := make(catalog)
+		if err := c.add("", messages); err != nil {
+			panic(err)
+		}
+		b.builtin[normalize(localeOf(f.Name()))] = c
+	} // for>
+	return b
+}
+
+// DefaultLocale returns the locale the missing messages fall back to.
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales returns the loaded locales, sorted.
+func (b *Bundle) Locales() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	locales := make([]string, 0, len(b.locales))
+	for _, l := range b.locales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// AddMessages adds the messages of the locale, the nested maps are named by the dotted keys,
+// and a map of the plural categories with "other" is a plural message.
+func (b *Bundle) AddMessages(locale string, messages map[string]interface{}) error {
+	c := make(catalog)
+	if err := c.add("", messages); err != nil {
+		return fmt.Errorf("i18n %s: %v", locale, err)
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	tag := normalize(locale)
+	if b.catalogs[tag] == nil {
+		b.catalogs[tag] = make(catalog)
+		b.locales[tag] = locale
+	}
+	for key, m := range c {
+		b.catalogs[tag][key] = m
+	}
+	return nil
+}
+
+// LoadFile adds the messages of the file, its locale is the last part of its name,
+// e.g. zh-CN.yaml and messages.zh-CN.yaml, the format is decided by its extension:
+// .json, .yaml, .yml or .toml
+func (b *Bundle) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return b.load(filepath.Base(path), data)
+}
+
+// LoadDir adds the messages of the catalog files in the dir.
+func (b *Bundle) LoadDir(dir string) error {
+	return b.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS adds the messages of the catalog files in the dir of fsys, e.g. an embed.FS.
+func (b *Bundle) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || formatOf(e.Name()) == "" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if err := b.load(e.Name(), data); err != nil {
+			return err
+		}
+	} // for>
+	return nil
+}
+
+func (b *Bundle) load(name string, data []byte) error {
+	messages := make(map[string]interface{})
+	var err error
+	switch formatOf(name) {
+	case "json":
+		err = json.Unmarshal(data, &messages)
+	case "yaml":
+		err = yaml.Unmarshal(data, &messages)
+	case "toml":
+		err = toml.Unmarshal(data, &messages)
+	default:
+		return fmt.Errorf("i18n %s: unknown format", name)
+	}
+	if err != nil {
+		return fmt.Errorf("i18n %s: %v", name, err)
+	}
+	return b.AddMessages(localeOf(name), messages)
+}
+
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return ""
+}
+
+// zh-CN of messages.zh-CN.yaml
+func localeOf(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// add the messages of the map, named after the prefix
+func (c catalog) add(prefix string, messages map[string]interface{}) error {
+	for key, value := range messages {
+		name := prefix + key
+		switch v := value.(type) {
+		case string:
+			c[name] = &message{text: v}
+		case map[string]interface{}:
+			if plural, ok := pluralForms(v); ok {
+				c[name] = &message{text: plural[Other], plural: plural}
+				continue
+			}
+			if err := c.add(name+".", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s: unexpected %T", name, value)
+		}
+	} // for>
+	return nil
+}
+
+// the map is of the plural forms if its keys are the plural categories with other
+func pluralForms(m map[string]interface{}) (map[string]string, bool) {
+	if _, ok := m[Other]; !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(m))
+	for key, value := range m {
+		s, ok := value.(string)
+		if !ok || !isCategory(key) {
+			return nil, false
+		}
+		forms[key] = s
+	} // for>
+	return forms, true
+}
+
+// $--- lookup ---
+// lookup returns the message of the key, in the locale, its language, or the default locale,
+// then in the builtin catalogs of them
+func (b *Bundle) lookup(locale, key string) (*message, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	chain := []string{normalize(locale), language(locale), normalize(b.defaultLocale), language(b.defaultLocale)}
+	for _, tag := range chain {
+		if m, ok := b.catalogs[tag][key]; ok {
+			return m, true
+		}
+	} // for>
+	// the builtin catalogs are of the regions, zh-CN is for zh too
+	for _, tag := range chain {
+		for name, c := range b.builtin {
+			if m, ok := c[key]; ok && (name == tag || language(name) == tag) {
+				return m, true
+			}
+		}
+	} // for>
+	return nil, false
+}
+
+// Match returns the loaded locale best matching the tags in order,
+// by the whole tag, then by the language, or the default locale.
+func (b *Bundle) Match(tags ...string) string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, tag := range tags {
+		if locale, ok := b.locales[normalize(tag)]; ok {
+			return locale
+		}
+		// zh matches zh-CN, and zh-TW matches zh
+		lang := language(tag)
+		if locale, ok := b.locales[lang]; ok {
+			return locale
+		}
+		for _, normalized := range sortedKeys(b.locales) {
+			if language(normalized) == lang {
+				return b.locales[normalized]
+			}
+		}
+	} // for>
+	return b.defaultLocale
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Localizer returns the Localizer of the locale matching the tags, see Match.
+func (b *Bundle) Localizer(tags ...string) *Localizer {
+	return &Localizer{bundle: b, locale: b.Match(tags...)}
+}
+
+// zh-cn of zh_CN
+func normalize(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+// zh of zh-CN
+func language(tag string) string {
+	tag = normalize(tag)
+	if i := strings.Index(tag, "-"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeCatalogs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBundle_Load(t *testing.T) {
+	dir := writeCatalogs(t, map[string]string{
+		"en.json": `{"greeting": "Hello, {name}", "cart": {"items": {"one": "{count} item", "other": "{count} items"}}}`,
+		"messages.zh-CN.yaml": `
+greeting: 你好，{name}
+cart:
+  items:
+    other: "{count} 件商品"
+`,
+		"ru.toml": `
+[cart.items]
+one = "{count} товар"
+few = "{count} товара"
+many = "{count} товаров"
+other = "{count} товара"
+`,
+		"README.md": "skipped",
+	})
+	b := NewBundle("en")
+	if err := b.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if locales := b.Locales(); !reflect.DeepEqual(locales, []string{"en", "ru", "zh-CN"}) {
+		t.Fatalf("locales = %v", locales)
+	}
+
+	en, zh, ru := b.Localizer("en-US"), b.Localizer("zh"), b.Localizer("ru")
+	cases := []struct {
+		l    *Localizer
+		key  string
+		args []interface{}
+		want string
+	}{
+		{en, "greeting", []interface{}{"name", "Tom"}, "Hello, Tom"},
+		{zh, "greeting", []interface{}{"name", "Tom"}, "你好，Tom"},
+		{en, "cart.items", []interface{}{"count", 1}, "1 item"},
+		{en, "cart.items", []interface{}{"count", 2}, "2 items"},
+		{zh, "cart.items", []interface{}{"count", 1}, "1 件商品"},
+		{ru, "cart.items", []interface{}{"count", 21}, "21 товар"},
+		{ru, "cart.items", []interface{}{"count", 3}, "3 товара"},
+		{ru, "cart.items", []interface{}{"count", 11}, "11 товаров"},
+		// the missing messages fall back to the default locale, then the key
+		{ru, "greeting", []interface{}{"name", "Tom"}, "Hello, Tom"},
+		{en, "missing {name}", []interface{}{"name", "Tom"}, "missing Tom"},
+		// the builtin messages
+		{zh, "validation.required", nil, "不能为空"},
+		{zh, "http.404", nil, "资源不存在"},
+	}
+	for _, c := range cases {
+		if got := c.l.T(c.key, c.args...); got != c.want {
+			t.Errorf("%s T(%s, %v) = %s, want %s", c.l.Locale(), c.key, c.args, got, c.want)
+		}
+	} // for>
+	if _, ok := en.Lookup("validation.required"); ok {
+		t.Error("the builtin messages of zh are used for en")
+	}
+
+	if err := b.LoadFile(filepath.Join(dir, "README.md")); err == nil {
+		t.Error("an unknown format is loaded")
+	}
+	if err := b.AddMessages("fr", map[string]interface{}{"n": 1}); err == nil {
+		t.Error("a number is loaded as a message")
+	}
+}
+
+func TestBundle_Match(t *testing.T) {
+	b := NewBundle("en")
+	for _, locale := range []string{"en", "zh-CN", "pt-BR"} {
+		_ = b.AddMessages(locale, map[string]interface{}{"k": locale})
+	}
+	cases := map[string]string{
+		"zh-CN,zh;q=0.9,en;q=0.8": "zh-CN",
+		"zh_cn":                   "zh-CN",
+		"zh-TW":                   "zh-CN",
+		"pt":                      "pt-BR",
+		"fr;q=0.9,pt-PT;q=0.5":    "pt-BR",
+		"en;q=0.1,zh;q=0.5":       "zh-CN",
+		"fr":                      "en",
+		"":                        "en",
+	}
+	for header, want := range cases {
+		if got := b.Match(ParseAcceptLanguage(header)...); got != want {
+			t.Errorf("Match(%q) = %s, want %s", header, got, want)
+		}
+	} // for>
+}
+
+func TestLocalizer_FuncMap(t *testing.T) {
+	b := NewBundle("en")
+	_ = b.AddMessages("zh-CN", map[string]interface{}{"hello": "你好，{name}"})
+	tmpl := template.Must(template.New("page").Funcs(FuncMap()).Parse(`<p lang="{{locale}}">{{T "hello" "name" .}}</p>`))
+
+	page := template.Must(tmpl.Clone()).Funcs(b.Localizer("zh-CN").FuncMap())
+	var out strings.Builder
+	if err := page.Execute(&out, "<Tom>"); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p lang="zh-CN">你好，&lt;Tom&gt;</p>`; out.String() != want {
+		t.Errorf("page = %s, want %s", out.String(), want)
+	}
+}
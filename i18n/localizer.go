@@ -0,0 +1,131 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PluralArg is the name of the argument choosing the plural form.
+const PluralArg = "count"
+
+// Localizer translates the messages into a locale.
+type Localizer struct {
+	bundle *Bundle
+	locale string
+}
+
+// Locale returns the locale of the localizer.
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// T returns the message of the key, with the {name} placeholders replaced by the args,
+// the args are the pairs of the names and the values, like "name", "tom", "count", 3,
+// and the plural form is chosen by the count.
+// The key itself is formatted if there is no such message.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	if s, ok := l.Lookup(key, args...); ok {
+		return s
+	}
+	return Format(key, args...)
+}
+
+// Lookup returns the message of the key like T, and false if there is no such message.
+func (l *Localizer) Lookup(key string, args ...interface{}) (string, bool) {
+	if l == nil || l.bundle == nil {
+		return "", false
+	}
+	m, ok := l.bundle.lookup(l.locale, key)
+	if !ok {
+		return "", false
+	}
+	text := m.text
+	if m.plural != nil {
+		if count, ok := argument(args, PluralArg); ok {
+			if n, ok := toFloat(count); ok {
+				if form, ok := m.plural[pluralRule(l.locale)(n)]; ok {
+					text = form
+				}
+			}
+		}
+	} // if>
+	return Format(text, args...), true
+}
+
+// FuncMap returns the template functions T and locale, for html/template and text/template,
+// e.g. tmpl.Funcs(c.Localizer().FuncMap()) on a clone of the parsed template.
+func (l *Localizer) FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"T":      l.T,
+		"locale": l.Locale,
+	}
+}
+
+// FuncMap returns the placeholders of the template functions of Localizer.FuncMap,
+// so the templates using them can be parsed before the requests.
+func FuncMap() map[string]interface{} {
+	var l *Localizer
+	return map[string]interface{}{
+		"T":      l.T,
+		"locale": func() string { return "" },
+	}
+}
+
+// Format replaces the {name} placeholders of the text with the args,
+// the args are the pairs of the names and the values.
+func Format(text string, args ...interface{}) string {
+	if len(args) < 2 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func argument(args []interface{}, name string) (interface{}, bool) {
+	for i := 0; i+1 < len(args); i += 2 {
+		if fmt.Sprint(args[i]) == name {
+			return args[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// ParseAcceptLanguage returns the tags of the Accept-Language header, by their quality,
+// e.g. "zh-CN,zh;q=0.9,en;q=0.8" is [zh-CN zh en].
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	tags := make([]weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		} // for>>
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	} // for>
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
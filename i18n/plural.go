@@ -0,0 +1,116 @@
+package i18n
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// the plural categories of CLDR
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+func isCategory(s string) bool {
+	switch s {
+	case Zero, One, Two, Few, Many, Other:
+		return true
+	}
+	return false
+}
+
+// PluralRule returns the plural category of the count.
+type PluralRule func(n float64) string
+
+var (
+	pluralRulesMux sync.RWMutex
+	pluralRules    = map[string]PluralRule{}
+)
+
+// RegisterPluralRule sets the plural rule of the languages, e.g. "ar",
+// the languages without a rule use the English one.
+func RegisterPluralRule(rule PluralRule, languages ...string) {
+	pluralRulesMux.Lock()
+	defer pluralRulesMux.Unlock()
+	for _, lang := range languages {
+		pluralRules[language(lang)] = rule
+	}
+}
+
+func pluralRule(locale string) PluralRule {
+	pluralRulesMux.RLock()
+	defer pluralRulesMux.RUnlock()
+	if rule, ok := pluralRules[language(locale)]; ok {
+		return rule
+	}
+	return pluralOne
+}
+
+// 1 is one, the others are other
+func pluralOne(n float64) string {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+// 0 and 1 are one
+func pluralZeroOne(n float64) string {
+	if n >= 0 && n < 2 {
+		return One
+	}
+	return Other
+}
+
+func pluralOther(n float64) string {
+	return Other
+}
+
+// the integers of the Slavic languages, by their last digits
+func pluralSlavic(oneOnlyOne bool) PluralRule {
+	return func(n float64) string {
+		if n != math.Trunc(n) {
+			return Other
+		}
+		i := int64(math.Abs(n))
+		mod10, mod100 := i%10, i%100
+		switch {
+		case oneOnlyOne && i == 1, !oneOnlyOne && mod10 == 1 && mod100 != 11:
+			return One
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return Few
+		}
+		return Many
+	}
+}
+
+func init() {
+	RegisterPluralRule(pluralOther, "zh", "ja", "ko", "vi", "th", "id", "ms", "lo", "my")
+	RegisterPluralRule(pluralOne, "en", "de", "nl", "sv", "da", "nb", "no", "fi", "et", "it", "es", "el", "hu", "tr", "bg", "ca")
+	RegisterPluralRule(pluralZeroOne, "fr", "pt")
+	RegisterPluralRule(pluralSlavic(false), "ru", "uk", "be")
+	RegisterPluralRule(pluralSlavic(true), "pl")
+}
+
+// the count of the plural messages, false if it is not a number
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
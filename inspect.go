@@ -0,0 +1,50 @@
+package tong
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// InspectRoutesEnv names the file the routes are written to by StartServer,
+// which exits instead of serving, it is set by the `tong routes` command.
+const InspectRoutesEnv = "TONG_INSPECT_ROUTES"
+
+// RouteEntry is a route in the route table.
+type RouteEntry struct {
+	Method  string   `json:"method"`
+	Path    string   `json:"path"`
+	Name    string   `json:"name"`
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Hidden  bool     `json:"hidden,omitempty"`
+}
+
+// WriteRoutes writes the route table as a JSON array of RouteEntry.
+func (t *Tong) WriteRoutes(w io.Writer) error {
+	routes := t.Routes()
+	entries := make([]RouteEntry, len(routes))
+	for i, r := range routes {
+		entries[i] = RouteEntry{Method: r.Method, Path: r.Path, Name: r.Name, Summary: r.Summary, Tags: r.Tags, Hidden: r.Hidden}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// inspectRoutes writes the route table to the file and exits
+func (t *Tong) inspectRoutes(path string) {
+	f, err := os.Create(path)
+	if err == nil {
+		err = t.WriteRoutes(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	} // if>
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tong: inspect routes:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,36 @@
+package tong_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestTong_WriteRoutes(t *testing.T) {
+	app := tongtest.NewApp()
+	app.POST("/users", noop).Doc("Create a user", "").Tag("users")
+	app.GET("/users", noop)
+	app.GET("/health", noop).Hide()
+
+	var buf bytes.Buffer
+	if err := app.WriteRoutes(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var routes []tong.RouteEntry
+	if err := json.Unmarshal(buf.Bytes(), &routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("routes = %+v", routes)
+	}
+	if r := routes[0]; r.Path != "/health" || !r.Hidden {
+		t.Errorf("routes[0] = %+v", r)
+	}
+	if r := routes[2]; r.Method != http.MethodPost || r.Summary != "Create a user" || r.Tags[0] != "users" || r.Name == "" {
+		t.Errorf("routes[2] = %+v", r)
+	}
+}
@@ -0,0 +1,66 @@
+package tong
+
+import (
+	"github.com/ming3000/tong/i18n"
+	"net/http"
+	"strconv"
+)
+
+// SetLocalizer sets the localizer of the request, e.g. by the locale detection middleware.
+func (c *Context) SetLocalizer(l *i18n.Localizer) {
+	c.localizer = l
+}
+
+// Localizer returns the localizer of the request, or nil.
+func (c *Context) Localizer() *i18n.Localizer {
+	return c.localizer
+}
+
+// Locale returns the locale of the request, or "" without a localizer.
+func (c *Context) Locale() string {
+	if c.localizer == nil {
+		return ""
+	}
+	return c.localizer.Locale()
+}
+
+// T translates the message of the key into the locale of the request, see i18n.Localizer.T,
+// the key is formatted with the args without a localizer.
+func (c *Context) T(key string, args ...interface{}) string {
+	if c.localizer == nil {
+		return i18n.Format(key, args...)
+	}
+	return c.localizer.T(key, args...)
+}
+
+// localizeHTTPError returns a copy of the error with its message and validation details translated:
+// the message is the key of its translation, or the default message of the status is http.<code>,
+// a validation error is validation.<rule>.<param> or validation.<rule>, with the field and the param.
+func (c *Context) localizeHTTPError(he *HTTPError) *HTTPError {
+	if c.localizer == nil {
+		return he
+	}
+	localized := *he
+	if s, ok := c.localizer.Lookup(he.Message); ok {
+		localized.Message = s
+	} else if he.Message == http.StatusText(he.Code) {
+		if s, ok := c.localizer.Lookup("http." + strconv.Itoa(he.Code)); ok {
+			localized.Message = s
+		}
+	} // else>
+
+	if errs, ok := he.Details.(ValidationErrors); ok {
+		translated := make(ValidationErrors, len(errs))
+		for i, e := range errs {
+			translated[i] = e
+			args := []interface{}{"field", e.Field, "param", e.Param}
+			if s, ok := c.localizer.Lookup("validation."+e.Rule+"."+e.Param, args...); ok && e.Param != "" {
+				translated[i].Message = s
+			} else if s, ok := c.localizer.Lookup("validation."+e.Rule, args...); ok {
+				translated[i].Message = s
+			}
+		} // for>>
+		localized.Details = translated
+	} // if>
+	return &localized
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/ming3000/tong"
+	"net"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	tong.RegisterMiddleware("blacklist", blacklistFromOptions)
+}
+
+// Blacklist returns a middleware rejecting the client ips with 403 Forbidden,
+// every entry is an ip or a CIDR, e.g. 10.0.0.0/8.
+func Blacklist(entries ...string) (tong.MiddlewareFunc, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		} // if>>
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blacklist entry %s: %v", entry, err)
+		} // if>>
+		nets = append(nets, ipNet)
+	} // for>
+
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			if ip := net.ParseIP(c.RealIP()); ip != nil {
+				for _, ipNet := range nets {
+					if ipNet.Contains(ip) {
+						return tong.NewHTTPError(http.StatusForbidden)
+					} // if>>>>>
+				} // for>>>>
+			} // if>>>
+			return next(c)
+		}
+	}, nil
+}
+
+// the blacklist middleware of the config, the options are
+// ips: the ips or CIDRs rejected
+func blacklistFromOptions(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+	var entries []string
+	for key, value := range options {
+		if key != "ips" {
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+		list, err := stringList(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %v", key, err)
+		}
+		entries = list
+	} // for>
+	return Blacklist(entries...)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ming3000/tong"
+)
+
+func TestBlacklist(t *testing.T) {
+	blacklist, err := Blacklist("10.0.0.1", "192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := tong.New()
+	app.AddCustomerMiddleware(blacklist)
+	app.GET("/users", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	for remote, want := range map[string]int{
+		"10.0.0.1:5000":    http.StatusForbidden,
+		"192.168.1.1:5000": http.StatusForbidden,
+		"10.0.0.2:5000":    http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: code = %d, want %d", remote, w.Code, want)
+		}
+	}
+	if _, err := Blacklist("10.0.0.0/33"); err == nil {
+		t.Error("invalid entry should be rejected")
+	}
+}
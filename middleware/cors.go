@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	tong.RegisterMiddleware("cors", corsFromOptions)
+}
+
+// CORSConfig is the config of the cross-origin resource sharing middleware.
+type CORSConfig struct {
+	// AllowOrigins are the origins allowed, "*" allows any origin
+	AllowOrigins []string
+	// AllowMethods are the methods allowed by the preflight requests,
+	// it defaults to GET, HEAD, PUT, PATCH, POST and DELETE
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by the preflight requests,
+	// the requested headers are allowed if empty
+	AllowHeaders []string
+	// MaxAge is the seconds the preflight responses are cached, not sent if zero
+	MaxAge int
+}
+
+// CORS returns a CORS middleware allowing the origins.
+func CORS(origins ...string) tong.MiddlewareFunc {
+	return CORSWithConfig(CORSConfig{AllowOrigins: origins})
+}
+
+// CORSWithConfig returns a CORS middleware, the preflight requests of the allowed origins are answered
+// with 204 No Content, the requests of the other origins are served without the CORS headers.
+func CORSWithConfig(config CORSConfig) tong.MiddlewareFunc {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete}
+	}
+	allowMethods := strings.Join(config.AllowMethods, ",")
+	allowHeaders := strings.Join(config.AllowHeaders, ",")
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			r := c.Request()
+			header := c.Response().Header()
+			header.Add(common.HeaderVary, common.HeaderOrigin)
+			origin := r.Header.Get(common.HeaderOrigin)
+			if origin == "" || !allowOrigin(config.AllowOrigins, origin) {
+				return next(c)
+			} // if>>
+			header.Set(common.HeaderAccessControlAllowOrigin, origin)
+
+			if r.Method != http.MethodOptions || r.Header.Get(common.HeaderAccessControlRequestMethod) == "" {
+				return next(c)
+			} // if>>
+			// the preflight request
+			header.Set(common.HeaderAccessControlAllowMethods, allowMethods)
+			if allowHeaders != "" {
+				header.Set(common.HeaderAccessControlAllowHeaders, allowHeaders)
+			} else if requested := r.Header.Get(common.HeaderAccessControlRequestHeaders); requested != "" {
+				header.Set(common.HeaderAccessControlAllowHeaders, requested)
+			} // else>>
+			if config.MaxAge > 0 {
+				header.Set(common.HeaderAccessControlMaxAge, strconv.Itoa(config.MaxAge))
+			} // if>>
+			c.Response().WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}
+}
+
+func allowOrigin(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// the cors middleware of the config, the options are
+// origins, methods and headers: the allowed ones, max_age: the seconds of the preflight cache
+func corsFromOptions(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+	config := CORSConfig{}
+	for key, value := range options {
+		if key == "max_age" {
+			seconds, err := number(value)
+			if err != nil {
+				return nil, fmt.Errorf("option %s: %v", key, err)
+			} // if>>>
+			config.MaxAge = int(seconds)
+			continue
+		} // if>>
+		names, err := stringList(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %v", key, err)
+		} // if>>
+		switch key {
+		case "origins":
+			config.AllowOrigins = names
+		case "methods":
+			config.AllowMethods = names
+		case "headers":
+			config.AllowHeaders = names
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	} // for>
+	return CORSWithConfig(config), nil
+}
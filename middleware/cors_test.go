@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ming3000/tong"
+)
+
+func TestCORS(t *testing.T) {
+	app := tong.New()
+	app.AddCustomerMiddleware(CORSWithConfig(CORSConfig{AllowOrigins: []string{"https://a.com"}, MaxAge: 60}))
+	app.GET("/users", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Origin", "https://a.com")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Fatal("allowed origin should get the CORS headers", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/users", nil)
+	req.Header.Set("Origin", "https://a.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "X-Token")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") != "X-Token" ||
+		w.Header().Get("Access-Control-Max-Age") != "60" {
+		t.Fatal("preflight should be answered", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Origin", "https://b.com")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("other origins should not get the CORS headers", w.Header())
+	}
+}
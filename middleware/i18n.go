@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"github.com/ming3000/tong/i18n"
+)
+
+func init() {
+	tong.RegisterMiddleware("i18n", i18nFromOptions)
+}
+
+// I18nConfig is the config of the locale detection middleware.
+type I18nConfig struct {
+	// Bundle is the catalogs of the supported locales
+	Bundle *i18n.Bundle
+	// QueryParam names the query param choosing the locale, it defaults to "lang", "-" to skip
+	QueryParam string
+	// CookieName names the cookie choosing the locale, it defaults to "lang", "-" to skip
+	CookieName string
+}
+
+// I18n returns a locale detection middleware with the default config.
+func I18n(bundle *i18n.Bundle) tong.MiddlewareFunc {
+	return I18nWithConfig(I18nConfig{Bundle: bundle})
+}
+
+// I18nWithConfig returns a middleware setting the localizer of each request,
+// its locale is chosen by the query param, the cookie, then the Accept-Language header,
+// and falls back to the default locale of the bundle.
+func I18nWithConfig(config I18nConfig) tong.MiddlewareFunc {
+	if config.QueryParam == "" {
+		config.QueryParam = "lang"
+	}
+	if config.CookieName == "" {
+		config.CookieName = "lang"
+	}
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			r := c.Request()
+			tags := make([]string, 0, 4)
+			if config.QueryParam != "-" {
+				if lang := r.URL.Query().Get(config.QueryParam); lang != "" {
+					tags = append(tags, lang)
+				}
+			} // if>
+			if config.CookieName != "-" {
+				if cookie, err := r.Cookie(config.CookieName); err == nil && cookie.Value != "" {
+					tags = append(tags, cookie.Value)
+				}
+			} // if>
+			tags = append(tags, i18n.ParseAcceptLanguage(r.Header.Get(common.HeaderAcceptLanguage))...)
+
+			localizer := config.Bundle.Localizer(tags...)
+			c.SetLocalizer(localizer)
+			c.Response().Header().Set(common.HeaderContentLanguage, localizer.Locale())
+			c.Response().Header().Add(common.HeaderVary, common.HeaderAcceptLanguage)
+			return next(c)
+		}
+	}
+}
+
+// the i18n middleware of the config, the options are
+// dir: the dir of the catalog files, default_locale: it defaults to en,
+// query: the query param, cookie: the cookie name
+func i18nFromOptions(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+	values := map[string]string{"default_locale": "en"}
+	for key, value := range options {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("option %s: %v is not a string", key, value)
+		}
+		switch key {
+		case "dir", "default_locale", "query", "cookie":
+			values[key] = s
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	} // for>
+	if values["dir"] == "" {
+		return nil, errors.New("option dir is required")
+	}
+	bundle := i18n.NewBundle(values["default_locale"])
+	if err := bundle.LoadDir(values["dir"]); err != nil {
+		return nil, err
+	}
+	return I18nWithConfig(I18nConfig{Bundle: bundle, QueryParam: values["query"], CookieName: values["cookie"]}), nil
+}
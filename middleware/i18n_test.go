@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/i18n"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type signup struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+func newI18nApp(t *testing.T) *tong.Tong {
+	t.Helper()
+	bundle := i18n.NewBundle("en")
+	_ = bundle.AddMessages("en", map[string]interface{}{
+		"welcome":   "Welcome, {name}",
+		"user.gone": "The user is gone",
+	})
+	_ = bundle.AddMessages("zh-CN", map[string]interface{}{
+		"welcome":   "欢迎，{name}",
+		"user.gone": "用户已注销",
+	})
+	app := tongtest.NewApp()
+	app.AddCustomerMiddleware(I18n(bundle))
+	app.GET("/welcome", func(c *tong.Context) error {
+		return c.String(http.StatusOK, c.T("welcome", "name", "tom"))
+	})
+	app.GET("/users/:id", func(c *tong.Context) error {
+		if c.Param("id") == "1" {
+			return tong.NewHTTPError(http.StatusGone, "user.gone")
+		}
+		return tong.NewHTTPError(http.StatusNotFound)
+	})
+	app.POST("/signup", func(c *tong.Context) error {
+		var s signup
+		return c.Bind(&s)
+	})
+	return app
+}
+
+func TestI18n_Locale(t *testing.T) {
+	client := tongtest.NewClient(newI18nApp(t))
+
+	client.GET("/welcome").Do(t).ExpectStatus(http.StatusOK).ExpectBody("Welcome, tom").
+		ExpectHeader("Content-Language", "en")
+	client.GET("/welcome").Header("Accept-Language", "fr;q=0.9,zh;q=0.8").Do(t).
+		ExpectBody("欢迎，tom").ExpectHeader("Content-Language", "zh-CN").ExpectHeader("Vary", "Accept-Language")
+	// the query param, then the cookie, win over the header
+	client.GET("/welcome").Query("lang", "en").Header("Cookie", "lang=zh-CN").Header("Accept-Language", "zh").Do(t).
+		ExpectBody("Welcome, tom")
+	client.GET("/welcome").Header("Cookie", "lang=zh-CN").Header("Accept-Language", "en").Do(t).
+		ExpectBody("欢迎，tom")
+}
+
+func TestI18n_Errors(t *testing.T) {
+	client := tongtest.NewClient(newI18nApp(t)).SetHeader("Accept-Language", "zh-CN")
+
+	client.GET("/users/1").Do(t).ExpectStatus(http.StatusGone).ExpectJSON(map[string]string{"message": "用户已注销"})
+	client.GET("/users/2").Do(t).ExpectStatus(http.StatusNotFound).ExpectJSON(map[string]string{"message": "资源不存在"})
+	client.POST("/signup").JSON(map[string]string{"email": "tom"}).Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectJSON(map[string]interface{}{
+		"message": "校验失败",
+		"details": []map[string]string{
+			{"field": "name", "rule": "required", "message": "不能为空"},
+			{"field": "email", "rule": "email", "message": "必须是邮箱地址"},
+		},
+	})
+
+	// the English messages are kept
+	tongtest.NewClient(newI18nApp(t)).GET("/users/1").Do(t).ExpectJSON(map[string]string{"message": "The user is gone"})
+	tongtest.NewClient(newI18nApp(t)).POST("/signup").JSON(map[string]string{}).Do(t).
+		ExpectJSON(map[string]interface{}{
+			"message": "validation failed",
+			"details": []map[string]string{{"field": "name", "rule": "required", "message": "is required"}},
+		})
+}
+
+func TestI18n_FromOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "zh-CN.yaml"), []byte("hi: 你好\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mw, err := i18nFromOptions(map[string]interface{}{"dir": dir, "default_locale": "zh-CN", "query": "locale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := tongtest.NewApp()
+	app.AddCustomerMiddleware(mw)
+	app.GET("/hi", func(c *tong.Context) error {
+		return c.String(http.StatusOK, c.Locale()+" "+c.T("hi"))
+	})
+	tongtest.NewClient(app).GET("/hi").Query("locale", "en").Do(t).ExpectBody("zh-CN 你好")
+
+	if _, err := i18nFromOptions(map[string]interface{}{}); err == nil {
+		t.Error("the dir is not required")
+	}
+	if _, err := i18nFromOptions(map[string]interface{}{"dir": dir, "lang": "en"}); err == nil {
+		t.Error("an unknown option is accepted")
+	}
+}
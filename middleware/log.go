@@ -1 +1,139 @@
 package middleware
+
+import (
+	"fmt"
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"net/http"
+	"net/url"
+)
+
+func init() {
+	tong.RegisterMiddleware("logger", loggerFromOptions)
+}
+
+// LoggerConfig is the config of the access log middleware.
+type LoggerConfig struct {
+	// Redactor masks the sensitive headers and query values,
+	// it defaults to common.NewRedactor()
+	Redactor *common.Redactor
+	// Headers are the request headers to log, all of them if empty
+	Headers []string
+}
+
+// Logger returns an access log middleware with the default config.
+func Logger() tong.MiddlewareFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// LoggerWithConfig returns an access log middleware,
+// it logs a record for every request with the logger of the request.
+func LoggerWithConfig(config LoggerConfig) tong.MiddlewareFunc {
+	if config.Redactor == nil {
+		config.Redactor = common.NewRedactor()
+	}
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			clock := c.Logger().Clock()
+			start := clock.Now()
+			err := next(c)
+
+			r := c.Request()
+			header := r.Header
+			if len(config.Headers) > 0 {
+				header = make(http.Header, len(config.Headers))
+				for _, name := range config.Headers {
+					if values, ok := r.Header[http.CanonicalHeaderKey(name)]; ok {
+						header[http.CanonicalHeaderKey(name)] = values
+					} // if>>>>
+				} // for>>>
+			} // if>>
+
+			fields := []interface{}{
+				"path", r.URL.Path,
+				"query", redactQuery(config.Redactor, r),
+				"status", c.Response().Status,
+				"size", c.Response().Size,
+				"latency", clock.Now().Sub(start).String(),
+				"user_agent", r.UserAgent(),
+				"headers", config.Redactor.Header(header),
+			}
+			if err != nil {
+				fields = append(fields, "error", config.Redactor.String(err.Error()))
+			} // if>>
+			c.Logger().With(fields...).Info("access")
+			return err
+		}
+	}
+}
+
+// the query string with the sensitive values masked
+func redactQuery(redactor *common.Redactor, r *http.Request) string {
+	query := r.URL.Query()
+	for key, values := range query {
+		for i, v := range values {
+			values[i] = redactor.Field(key, v).(string)
+		} // for>
+	} // for>
+	// keep the masks readable
+	encoded, err := url.QueryUnescape(query.Encode())
+	if err != nil {
+		return query.Encode()
+	} // if>
+	return encoded
+}
+
+// the logger middleware of the config, the options are
+// headers: the request headers to log, redact_fields: the fields to mask
+func loggerFromOptions(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+	config := LoggerConfig{Redactor: common.NewRedactor()}
+	for key, value := range options {
+		names, err := stringList(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %v", key, err)
+		} // if>
+		switch key {
+		case "headers":
+			config.Headers = names
+		case "redact_fields":
+			config.Redactor.Fields(names...)
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	} // for>
+	return LoggerWithConfig(config), nil
+}
+
+// the option decoded from JSON, YAML or TOML as a list of strings
+func stringList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		ret := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a string", item)
+			} // if>>
+			ret = append(ret, s)
+		} // for>
+		return ret, nil
+	}
+	return nil, fmt.Errorf("%v is not a list of strings", value)
+}
+
+// the option decoded from JSON, YAML or TOML as a number
+func number(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", value)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+)
+
+func TestLogger_Redacts(t *testing.T) {
+	buf := new(bytes.Buffer)
+	app := tong.New()
+	app.Logger.SetOutput(buf)
+	app.AddCustomerMiddleware(LoggerWithConfig(LoggerConfig{
+		Redactor: common.NewRedactor().Fields("token"),
+	}))
+	app.GET("/users", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users?token=s3cr3t-query&page=2", nil)
+	req.Header.Set("Authorization", "Basic s3cr3t-auth")
+	req.Header.Set("Cookie", "id=s3cr3t-cookie")
+	req.Header.Set("User-Agent", "test-agent")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := buf.String()
+	if strings.Contains(out, "s3cr3t") {
+		t.Fatal("secrets should be masked", out)
+	}
+	for _, want := range []string{"INFO access", "path=/users", "page=2", "token=******", "status=200", "test-agent"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("access log should contain %q: %s", want, out)
+		}
+	}
+}
+
+func TestLogger_Latency(t *testing.T) {
+	buf := new(bytes.Buffer)
+	clock := common.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	app := tong.New()
+	app.Logger.SetOutput(buf)
+	app.SetClock(clock)
+	app.AddCustomerMiddleware(Logger())
+	app.GET("/slow", func(c *tong.Context) error {
+		clock.Advance(1500 * time.Millisecond)
+		return c.String(http.StatusOK, "ok")
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if out := buf.String(); !strings.Contains(out, "latency=1.5s") {
+		t.Fatal("latency should follow the clock of the app", out)
+	}
+}
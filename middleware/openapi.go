@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIValidatorConfig is the config of the OpenAPI validation middleware.
+type OpenAPIValidatorConfig struct {
+	// Spec is the document the requests are validated against
+	Spec *tong.OpenAPI
+	// ValidateResponses validates the responses too, e.g. with app.Debug,
+	// an invalid response is replaced by an error of StatusInternalServerError
+	ValidateResponses bool
+}
+
+// OpenAPIValidator returns a middleware validating the requests against the spec.
+func OpenAPIValidator(spec *tong.OpenAPI) tong.MiddlewareFunc {
+	return OpenAPIValidatorWithConfig(OpenAPIValidatorConfig{Spec: spec})
+}
+
+// OpenAPIValidatorWithConfig returns a middleware matching each request to an operation of the spec,
+// and validating its parameters and body against the schemas,
+// an invalid request is an HTTPError of StatusBadRequest with the failures as its details.
+// The requests not in the spec are passed through.
+func OpenAPIValidatorWithConfig(config OpenAPIValidatorConfig) tong.MiddlewareFunc {
+	spec := config.Spec
+	router := tong.NewRouter()
+	operations := make(map[string]*tong.OpenAPIOperation)
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			route := router.Add(strings.ToUpper(method), routerPath(path), func(c *tong.Context) error { return nil })
+			operations[route.Method+route.Path] = op
+		} // for>>
+	} // for>
+
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			r := c.Request()
+			route, params := router.Lookup(r.Method, r.URL.Path)
+			if route == nil {
+				return next(c)
+			}
+			op := operations[route.Method+route.Path]
+			if err := validateRequest(spec, op, r, params); err != nil {
+				return err
+			}
+			if !config.ValidateResponses {
+				return next(c)
+			}
+			return validateResponse(spec, op, c, next)
+		}
+	}
+}
+
+// the router path of the spec path, /users/:id for /users/{id}
+func routerPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			segments[i] = ":" + s[1:len(s)-1]
+		}
+	} // for>
+	return strings.Join(segments, "/")
+}
+
+func validateRequest(spec *tong.OpenAPI, op *tong.OpenAPIOperation, r *http.Request, params map[string]string) error {
+	errs := make(tong.ValidationErrors, 0)
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			if v, ok := params[p.Name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = r.URL.Query()[p.Name]
+		case "header":
+			values = r.Header.Values(p.Name)
+		case "cookie":
+			if cookie, err := r.Cookie(p.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		if len(values) == 0 {
+			if p.Required {
+				errs = append(errs, tong.FieldError{Field: p.Name, Rule: "required", Message: "is required"})
+			}
+			continue
+		} // if>>
+		spec.ValidateValue(p.Name, p.Schema, spec.Coerce(p.Schema, values), &errs)
+	} // for>
+
+	if op.RequestBody != nil {
+		if err := validateRequestBody(spec, op.RequestBody, r, &errs); err != nil {
+			return err
+		}
+	} // if>
+	if len(errs) > 0 {
+		return tong.NewHTTPError(http.StatusBadRequest, "request validation failed").SetDetails(errs).SetInternal(errs)
+	}
+	return nil
+}
+
+func validateRequestBody(spec *tong.OpenAPI, body *tong.OpenAPIRequestBody, r *http.Request, errs *tong.ValidationErrors) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		if body.Required {
+			*errs = append(*errs, tong.FieldError{Field: "body", Rule: "required", Message: "is required"})
+		}
+		return nil
+	} // if>
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(common.HeaderContentType))
+	content, ok := body.Content[mediaType]
+	if !ok {
+		content, ok = body.Content["*/*"]
+	}
+	if !ok {
+		return tong.NewHTTPError(http.StatusUnsupportedMediaType)
+	}
+
+	switch {
+	case mediaType == common.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return tong.NewHTTPError(http.StatusBadRequest, "invalid body: "+err.Error()).SetInternal(err)
+		}
+		// the handler reads the body again
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return tong.NewHTTPError(http.StatusBadRequest, "invalid body: "+err.Error()).SetInternal(err)
+		}
+		validateBody(spec, content.Schema, value, errs)
+	case mediaType == common.MIMEApplicationForm:
+		if err := r.ParseForm(); err != nil {
+			return tong.NewHTTPError(http.StatusBadRequest, "invalid body: "+err.Error()).SetInternal(err)
+		}
+		// the form values are converted by the types of the properties
+		value := make(map[string]interface{}, len(r.PostForm))
+		schema := spec.Resolve(content.Schema)
+		for key, values := range r.PostForm {
+			var property *tong.Schema
+			if schema != nil {
+				property = schema.Properties[key]
+			}
+			value[key] = spec.Coerce(property, values)
+			if property == nil {
+				value[key] = values[0]
+			}
+		} // for>>
+		validateBody(spec, content.Schema, value, errs)
+	}
+	return nil
+}
+
+// the root of the body is named "body", and its fields by their names
+func validateBody(spec *tong.OpenAPI, schema *tong.Schema, value interface{}, errs *tong.ValidationErrors) {
+	bodyErrs := make(tong.ValidationErrors, 0)
+	spec.ValidateValue("", schema, value, &bodyErrs)
+	for _, e := range bodyErrs {
+		if e.Field == "" {
+			e.Field = "body"
+		}
+		*errs = append(*errs, e)
+	} // for>
+}
+
+// $--- response validation ---
+// bufferedWriter keeps the response until it is validated
+type bufferedWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func validateResponse(spec *tong.OpenAPI, op *tong.OpenAPIOperation, c *tong.Context, next tong.HandlerFunc) error {
+	resp := c.Response()
+	original := resp.Writer
+	buffered := &bufferedWriter{ResponseWriter: original, code: http.StatusOK}
+	resp.Writer = buffered
+	err := next(c)
+	resp.Writer = original
+
+	if err == nil && resp.IfHeaderBeenSet {
+		errs := checkResponse(spec, op, buffered.code, original.Header().Get(common.HeaderContentType), buffered.body.Bytes())
+		if len(errs) > 0 {
+			c.Logger().With("errors", errs.Error()).ErrorFormat("invalid response of %s %s", c.Request().Method, c.Path())
+			// drop the invalid response, for the error handler
+			resp.Reset(original)
+			original.Header().Del(common.HeaderContentType)
+			return tong.NewHTTPError(http.StatusInternalServerError, "response validation failed").SetDetails(errs).SetInternal(errs)
+		}
+	} // if>
+	if resp.IfHeaderBeenSet {
+		original.WriteHeader(buffered.code)
+		if _, writeErr := original.Write(buffered.body.Bytes()); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	} // if>
+	return err
+}
+
+func checkResponse(spec *tong.OpenAPI, op *tong.OpenAPIOperation, code int, contentType string, body []byte) tong.ValidationErrors {
+	errs := make(tong.ValidationErrors, 0)
+	status := strconv.Itoa(code)
+	doc, ok := op.Responses[status]
+	if !ok {
+		doc, ok = op.Responses[status[:1]+"XX"]
+	}
+	if !ok {
+		doc, ok = op.Responses["default"]
+	}
+	if !ok {
+		return append(errs, tong.FieldError{Field: "status", Rule: "responses", Param: status, Message: "is not documented"})
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	content, ok := doc.Content[mediaType]
+	if !ok || content.Schema == nil || len(body) == 0 ||
+		(mediaType != common.MIMEApplicationJSON && !strings.HasSuffix(mediaType, "+json")) {
+		return errs
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return append(errs, tong.FieldError{Field: "body", Rule: "json", Message: err.Error()})
+	}
+	validateBody(spec, content.Schema, value, &errs)
+	return errs
+}
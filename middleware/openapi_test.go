@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+const usersSpec = `
+openapi: 3.1.0
+info: {title: users, version: "1"}
+paths:
+  /users/{id}:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: integer, minimum: 1}}
+    get:
+      parameters:
+        - {name: fields, in: query, schema: {type: array, items: {type: string, enum: [name, email]}}}
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/User"}
+    put:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/User"}
+      responses:
+        "204": {description: No Content}
+components:
+  schemas:
+    User:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string, minLength: 2}
+        email: {type: string, format: email}
+        tags: {type: array, maxItems: 2, items: {type: string}}
+`
+
+func newSpecApp(t *testing.T, validateResponses bool, user interface{}) *tong.Tong {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(path, []byte(usersSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := tong.LoadOpenAPI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := tongtest.NewApp()
+	app.AddCustomerMiddleware(OpenAPIValidatorWithConfig(OpenAPIValidatorConfig{Spec: spec, ValidateResponses: validateResponses}))
+	app.GET("/users/:id", func(c *tong.Context) error {
+		return c.Json(http.StatusOK, user, "")
+	})
+	app.Add(http.MethodPut, "/users/:id", func(c *tong.Context) error {
+		// the body is still readable
+		var u map[string]interface{}
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		c.Response().WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	app.GET("/health", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	return app
+}
+
+func TestOpenAPIValidator_Requests(t *testing.T) {
+	client := tongtest.NewClient(newSpecApp(t, false, map[string]string{"name": "tom"}))
+
+	client.GET("/users/1").Query("fields", "name").Do(t).ExpectStatus(http.StatusOK)
+	client.NewRequest(http.MethodPut, "/users/1").JSON(map[string]interface{}{"name": "tom", "tags": []string{"a"}}).Do(t).
+		ExpectStatus(http.StatusNoContent)
+	// not in the spec
+	client.GET("/health").Do(t).ExpectStatus(http.StatusOK)
+
+	client.GET("/users/0").Query("fields", "age").Do(t).ExpectStatus(http.StatusBadRequest).ExpectJSON(map[string]interface{}{
+		"message": "request validation failed",
+		"details": []map[string]string{
+			{"field": "fields[0]", "rule": "enum", "message": "must be one of the enum values"},
+			{"field": "id", "rule": "minimum", "param": "1", "message": "must be at least 1"},
+		},
+	})
+	client.GET("/users/x").Do(t).ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"rule":"type"`)
+
+	client.NewRequest(http.MethodPut, "/users/1").JSON(map[string]interface{}{"email": "tom", "tags": []string{"a", "b", "c"}}).Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectJSON(map[string]interface{}{
+		"message": "request validation failed",
+		"details": []map[string]string{
+			{"field": "name", "rule": "required", "message": "is required"},
+			{"field": "email", "rule": "format", "param": "email", "message": "must be an email address"},
+			{"field": "tags", "rule": "maxItems", "param": "2", "message": "must be at most 2 items"},
+		},
+	})
+	client.NewRequest(http.MethodPut, "/users/1").Do(t).ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"field":"body"`)
+	client.NewRequest(http.MethodPut, "/users/1").Body(strings.NewReader("tom"), "text/plain").Do(t).
+		ExpectStatus(http.StatusUnsupportedMediaType)
+}
+
+func TestOpenAPIValidator_Responses(t *testing.T) {
+	// without the response validation, the invalid response is sent
+	tongtest.NewClient(newSpecApp(t, false, map[string]int{"name": 1})).GET("/users/1").Do(t).
+		ExpectStatus(http.StatusOK).ExpectBody("{\"name\":1}\n")
+
+	client := tongtest.NewClient(newSpecApp(t, true, map[string]int{"name": 1}))
+	client.GET("/users/1").Do(t).ExpectStatus(http.StatusInternalServerError).ExpectJSON(map[string]interface{}{
+		"message": "response validation failed",
+		"details": []map[string]string{{"field": "name", "rule": "type", "param": "string", "message": "must be of type string"}},
+	})
+
+	tongtest.NewClient(newSpecApp(t, true, map[string]string{"name": "tom"})).GET("/users/1").Do(t).
+		ExpectStatus(http.StatusOK).ExpectHeader("Content-Type", "application/json; charset=UTF-8").
+		ExpectJSON(map[string]string{"name": "tom"})
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/ming3000/tong"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func init() {
+	tong.RegisterMiddleware("ratelimit", rateLimitFromOptions)
+}
+
+// RateLimitConfig is the config of the rate limit middleware.
+type RateLimitConfig struct {
+	// Rate is the requests allowed per second of each client
+	Rate float64
+	// Burst is the requests allowed at once, it defaults to 1
+	Burst int
+	// Key identifies the client of the request, it defaults to Context.RealIP
+	Key func(c *tong.Context) string
+}
+
+// RateLimit returns a rate limit middleware allowing rate requests per second of each client ip.
+func RateLimit(rate float64, burst int) tong.MiddlewareFunc {
+	return RateLimitWithConfig(RateLimitConfig{Rate: rate, Burst: burst})
+}
+
+// RateLimitWithConfig returns a rate limit middleware, which keeps a token bucket for each client,
+// the requests over the limit are rejected with 429 Too Many Requests.
+// The buckets are measured by the clock of the logger, see Tong.SetClock.
+func RateLimitWithConfig(config RateLimitConfig) tong.MiddlewareFunc {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.Key == nil {
+		config.Key = func(c *tong.Context) string { return c.RealIP() }
+	}
+	limiter := &rateLimiter{config: config, buckets: make(map[string]*tokenBucket)}
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			if !limiter.allow(config.Key(c), c.Logger().Clock().Now()) {
+				return tong.NewHTTPError(http.StatusTooManyRequests)
+			} // if>>
+			return next(c)
+		}
+	}
+}
+
+// the buckets are swept when there are more clients
+const maxRateLimitBuckets = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	config  RateLimitConfig
+	buckets map[string]*tokenBucket
+	lock    sync.Mutex
+}
+
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.sweep(now)
+		} // if>>
+		b = &tokenBucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = b
+	} // if>
+	b.refill(now, l.config)
+	if b.tokens < 1 {
+		return false
+	} // if>
+	b.tokens--
+	return true
+}
+
+// remove the full buckets, they are the same as the new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.refill(now, l.config); b.tokens >= float64(l.config.Burst) {
+			delete(l.buckets, key)
+		} // if>>
+	} // for>
+}
+
+func (b *tokenBucket) refill(now time.Time, config RateLimitConfig) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * config.Rate
+		if b.tokens > float64(config.Burst) {
+			b.tokens = float64(config.Burst)
+		}
+		b.last = now
+	} // if>
+}
+
+// the ratelimit middleware of the config, the options are
+// rate: the requests per second of each client ip, burst: the requests at once
+func rateLimitFromOptions(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+	config := RateLimitConfig{}
+	for key, value := range options {
+		n, err := number(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %v", key, err)
+		} // if>
+		switch key {
+		case "rate":
+			config.Rate = n
+		case "burst":
+			config.Burst = int(n)
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	} // for>
+	if config.Rate <= 0 {
+		return nil, fmt.Errorf("option rate must be positive")
+	}
+	return RateLimitWithConfig(config), nil
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+)
+
+func TestRateLimit(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	app := tong.New()
+	app.SetClock(clock)
+	app.AddCustomerMiddleware(RateLimit(1, 2))
+	app.GET("/users", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	get := func(remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := get("10.0.0.1:5000"); code != want {
+			t.Fatalf("request %d: code = %d, want %d", i, code, want)
+		}
+	}
+	if code := get("10.0.0.2:5000"); code != http.StatusOK {
+		t.Fatal("other clients should have their own limit", code)
+	}
+	clock.Advance(time.Second)
+	if code := get("10.0.0.1:5000"); code != http.StatusOK {
+		t.Fatal("tokens should be refilled by the rate", code)
+	}
+}
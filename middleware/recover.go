@@ -1 +1,36 @@
 package middleware
+
+import (
+	"github.com/ming3000/tong"
+	"net/http"
+)
+
+func init() {
+	tong.RegisterMiddleware("recover", func(options map[string]interface{}) (tong.MiddlewareFunc, error) {
+		return Recover(), nil
+	})
+}
+
+// Recover returns a middleware recovering the panics of the next handlers,
+// a panic is logged with its stack, and returned as a *tong.PanicError,
+// which is rendered by the error handler, with its stack on the debug page.
+func Recover() tong.MiddlewareFunc {
+	return func(next tong.HandlerFunc) tong.HandlerFunc {
+		return func(c *tong.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				// the server aborts the response
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				pe := tong.NewPanicError(r)
+				c.Logger().With("stack", pe.Stack()).Error(pe.Error())
+				err = pe
+			}()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestRecover(t *testing.T) {
+	buf := new(bytes.Buffer)
+	app := tongtest.NewApp()
+	app.Debug = true
+	app.Logger.SetOutput(buf)
+	app.AddCustomerMiddleware(Recover())
+	app.GET("/users/:id", func(c *tong.Context) error {
+		panic("boom")
+	})
+	client := tongtest.NewClient(app)
+
+	client.GET("/users/7").Header("Accept", "text/html").Header("Authorization", "Bearer s3cr3t").Do(t).
+		ExpectStatus(http.StatusInternalServerError).
+		ExpectHeader("Content-Type", "text/html; charset=UTF-8").
+		ExpectBodyContains("panic: boom").
+		ExpectBodyContains("recover_test.go").
+		ExpectBodyContains(`panic(&#34;boom&#34;)`).
+		ExpectBodyContains("/users/:id").
+		ExpectBodyContains("middleware.Recover")
+	if !strings.Contains(buf.String(), "panic: boom") || !strings.Contains(buf.String(), "TestRecover") {
+		t.Errorf("the panic is not logged with its stack: %s", buf.String())
+	}
+
+	var body struct {
+		Message string `json:"message"`
+		Debug   struct {
+			Errors []struct{ Type, Message string }
+			Stack  []struct {
+				Function string
+				Source   []struct{ Current bool }
+			}
+			Route   struct{ Path string }
+			Params  map[string]string
+			Headers map[string][]string
+		}
+	}
+	client.GET("/users/7").Header("Accept", "application/json").Header("Authorization", "Bearer s3cr3t").Do(t).
+		ExpectStatus(http.StatusInternalServerError).DecodeJSON(&body)
+	debug := body.Debug
+	if body.Message != "panic: boom" || debug.Errors[0].Type != "*tong.PanicError" || debug.Route.Path != "/users/:id" || debug.Params["id"] != "7" {
+		t.Errorf("body = %+v", body)
+	}
+	if len(debug.Stack) == 0 || !strings.Contains(debug.Stack[0].Function, "TestRecover") || len(debug.Stack[0].Source) == 0 {
+		t.Errorf("stack = %+v", debug.Stack)
+	}
+	if debug.Headers["Authorization"][0] != "******" {
+		t.Errorf("headers = %v", debug.Headers)
+	}
+
+	// the clients not accepting HTML or JSON explicitly, and without app.Debug
+	client.GET("/users/7").Do(t).ExpectStatus(http.StatusInternalServerError).ExpectBody("panic: boom")
+	app.Debug = false
+	client.GET("/users/7").Header("Accept", "text/html").Do(t).ExpectStatus(http.StatusInternalServerError).ExpectBody("panic: boom")
+}
@@ -0,0 +1,407 @@
+package tong
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// $--- route metadata ---
+// ParamInfo describes a path, query or header parameter of a route.
+type ParamInfo struct {
+	In          string
+	Name        string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Doc sets the summary and the description of the route.
+func (r *RouteInfo) Doc(summary, description string) *RouteInfo {
+	r.Summary, r.Description = summary, description
+	return r
+}
+
+func (r *RouteInfo) Tag(tags ...string) *RouteInfo {
+	r.Tags = append(r.Tags, tags...)
+	return r
+}
+
+// Reads sets the request type of the route, its fields are documented by the tags:
+// param, query and header fields are parameters, json and form fields are the body.
+func (r *RouteInfo) Reads(request interface{}) *RouteInfo {
+	r.Request = reflect.TypeOf(request)
+	return r
+}
+
+// Returns documents a response of the route, the value is nil for no body.
+func (r *RouteInfo) Returns(code int, response interface{}) *RouteInfo {
+	if r.Responses == nil {
+		r.Responses = make(map[int]reflect.Type)
+	}
+	r.Responses[code] = reflect.TypeOf(response)
+	return r
+}
+
+// Param documents a string parameter in the path, query or header.
+func (r *RouteInfo) Param(in, name, description string, required bool) *RouteInfo {
+	r.Params = append(r.Params, ParamInfo{
+		In: in, Name: name, Description: description, Required: required,
+		Schema: &Schema{Types: []string{"string"}},
+	})
+	return r
+}
+
+// Hide leaves the route out of the OpenAPI document.
+func (r *RouteInfo) Hide() *RouteInfo {
+	r.Hidden = true
+	return r
+}
+
+// Routes returns the registered routes, sorted by the path and the method.
+func (t *Tong) Routes() []*RouteInfo {
+	routes := make([]*RouteInfo, 0, len(t.router.routes))
+	for _, r := range t.router.routes {
+		routes = append(routes, r)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// $--- document define ---
+// OpenAPI is an OpenAPI 3.1 document.
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIConfig is the config of the OpenAPI document and its endpoints.
+type OpenAPIConfig struct {
+	Title       string
+	Version     string
+	Description string
+	// the path of the JSON document, it defaults to /openapi.json
+	SpecPath string
+	// the path of the viewer, it defaults to /docs, "-" to disable it
+	DocsPath string
+}
+
+// $--- generation ---
+// OpenAPI returns the OpenAPI 3.1 document of the routes,
+// generated by reflecting the request and response types of their metadata.
+func (t *Tong) OpenAPI() *OpenAPI {
+	config := t.openAPIConfig
+	if config.Title == "" {
+		config.Title = "Tong API"
+	}
+	if config.Version == "" {
+		config.Version = "1.0.0"
+	}
+	doc := &OpenAPI{
+		OpenAPI: "3.1.0",
+		Info:    OpenAPIInfo{Title: config.Title, Version: config.Version, Description: config.Description},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	registry := NewSchemaRegistry()
+	for _, r := range t.Routes() {
+		if r.Hidden {
+			continue
+		}
+		path := openAPIPath(r.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = r.operation(registry)
+	} // for>
+	doc.Components.Schemas = registry.Schemas
+	return doc
+}
+
+// openAPIPath writes the path params as {id} instead of :id
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (r *RouteInfo) operation(registry *SchemaRegistry) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     r.Summary,
+		Description: r.Description,
+		Tags:        r.Tags,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	declared := make(map[string]bool)
+	addParam := func(p *OpenAPIParameter) {
+		if !declared[p.In+":"+p.Name] {
+			declared[p.In+":"+p.Name] = true
+			op.Parameters = append(op.Parameters, p)
+		}
+	}
+	if r.Request != nil {
+		r.requestParams(registry, addParam)
+		op.RequestBody = r.requestBody(registry)
+	}
+	for _, p := range r.Params {
+		addParam(&OpenAPIParameter{Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: p.Schema})
+	}
+	for _, s := range strings.Split(r.Path, "/") {
+		if strings.HasPrefix(s, ":") {
+			addParam(&OpenAPIParameter{Name: s[1:], In: "path", Required: true, Schema: &Schema{Types: []string{"string"}}})
+		}
+	}
+
+	for code, typ := range r.Responses {
+		resp := &OpenAPIResponse{Description: http.StatusText(code)}
+		if typ != nil {
+			resp.Content = map[string]*OpenAPIMediaType{
+				"application/json": {Schema: registry.Schema(typ)},
+			}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	} // for>
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+	return op
+}
+
+func (r *RouteInfo) requestParams(registry *SchemaRegistry, add func(p *OpenAPIParameter)) {
+	typ := r.Request
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+	registry.walkFields(typ, func(field reflect.StructField) {
+		for _, in := range []string{"path", "query", "header"} {
+			tag := in
+			if in == "path" {
+				tag = "param"
+			}
+			name := FieldName(field, tag)
+			if name == "" {
+				continue
+			}
+			schema := registry.Field(field)
+			add(&OpenAPIParameter{
+				Name:        name,
+				In:          in,
+				Description: schema.Description,
+				Required:    in == "path" || hasRule(ParseValidateTag(field.Tag.Get("validate")), "required"),
+				Schema:      schema,
+			})
+		} // for>
+	})
+}
+
+func (r *RouteInfo) requestBody(registry *SchemaRegistry) *OpenAPIRequestBody {
+	typ := r.Request
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	body := &OpenAPIRequestBody{Required: true, Content: make(map[string]*OpenAPIMediaType)}
+	if typ.Kind() != reflect.Struct {
+		body.Content["application/json"] = &OpenAPIMediaType{Schema: registry.Schema(typ)}
+		return body
+	}
+
+	if jsonBody := registry.Object(typ, "json"); len(jsonBody.Properties) > 0 {
+		if typ.Name() != "" && !hasParamFields(typ) {
+			// the whole struct is the body, refer to its component
+			jsonBody = registry.Schema(typ)
+		}
+		body.Content["application/json"] = &OpenAPIMediaType{Schema: jsonBody}
+	}
+	if form := registry.Object(typ, "form"); len(form.Properties) > 0 {
+		body.Content["application/x-www-form-urlencoded"] = &OpenAPIMediaType{Schema: form}
+	}
+	if len(body.Content) == 0 {
+		return nil
+	}
+	return body
+}
+
+func hasParamFields(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		tag := typ.Field(i).Tag
+		if tag.Get("param") != "" || tag.Get("query") != "" || tag.Get("header") != "" || tag.Get("form") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// $--- endpoints ---
+//
+//go:embed openapi.html
+var openAPIViewer string
+
+var openAPIViewerTemplate = template.Must(template.New("openapi").Parse(openAPIViewer))
+
+// ServeOpenAPI serves the OpenAPI document and a viewer of it,
+// the document is generated on every request, so the routes added later are included.
+func (t *Tong) ServeOpenAPI(config OpenAPIConfig) {
+	if config.SpecPath == "" {
+		config.SpecPath = "/openapi.json"
+	}
+	if config.DocsPath == "" {
+		config.DocsPath = "/docs"
+	}
+	t.openAPIConfig = config
+
+	t.GET(config.SpecPath, func(c *Context) error {
+		return c.Json(http.StatusOK, t.OpenAPI(), "")
+	}).Hide()
+	if config.DocsPath == "-" {
+		return
+	}
+	t.GET(config.DocsPath, func(c *Context) error {
+		c.WriteContentType("text/html; charset=UTF-8")
+		c.Response().WriteHeader(http.StatusOK)
+		return openAPIViewerTemplate.Execute(c.Response(), map[string]string{
+			"Title":    t.OpenAPI().Info.Title,
+			"SpecPath": config.SpecPath,
+		})
+	}).Hide()
+}
+
+// $--- loading ---
+// the methods of a path item, the other keys are not operations
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// LoadOpenAPI reads an OpenAPI document in JSON or YAML,
+// the parameters of a path item are merged into its operations.
+func LoadOpenAPI(path string) (*OpenAPI, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("openapi %s: %v", path, err)
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("openapi %s: %v", path, err)
+		}
+	} // if>
+
+	var raw struct {
+		OpenAPI    string                                `json:"openapi"`
+		Info       OpenAPIInfo                           `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components OpenAPIComponents                     `json:"components"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("openapi %s: %v", path, err)
+	}
+	doc := &OpenAPI{
+		OpenAPI:    raw.OpenAPI,
+		Info:       raw.Info,
+		Paths:      make(map[string]map[string]*OpenAPIOperation),
+		Components: raw.Components,
+	}
+	for p, item := range raw.Paths {
+		var shared []*OpenAPIParameter
+		if params, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(params, &shared); err != nil {
+				return nil, fmt.Errorf("openapi %s: %s parameters: %v", path, p, err)
+			}
+		} // if>>
+		doc.Paths[p] = make(map[string]*OpenAPIOperation)
+		for _, method := range openAPIMethods {
+			if item[method] == nil {
+				continue
+			}
+			op := new(OpenAPIOperation)
+			if err := json.Unmarshal(item[method], op); err != nil {
+				return nil, fmt.Errorf("openapi %s: %s %s: %v", path, method, p, err)
+			}
+			op.Parameters = mergeParameters(shared, op.Parameters)
+			doc.Paths[p][method] = op
+		} // for>>
+	} // for>
+	return doc, nil
+}
+
+// the parameters of an operation override the shared ones of the same name and location
+func mergeParameters(shared, own []*OpenAPIParameter) []*OpenAPIParameter {
+	merged := append([]*OpenAPIParameter(nil), own...)
+	for _, s := range shared {
+		overridden := false
+		for _, o := range own {
+			if o.Name == s.Name && o.In == s.In {
+				overridden = true
+			}
+		}
+		if !overridden {
+			merged = append(merged, s)
+		}
+	} // for>
+	return merged
+}
@@ -0,0 +1,155 @@
+package tong_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" validate:"required,min=2,max=32" description:"the display name"`
+	Email     string    `json:"email,omitempty" validate:"omitempty,email"`
+	Role      string    `json:"role" validate:"oneof=admin member"`
+	Address   *Address  `json:"address,omitempty"`
+	Friends   []*User   `json:"friends,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	secret    string
+}
+
+type listUsersRequest struct {
+	Page  int    `query:"page" validate:"omitempty,min=1"`
+	Token string `header:"X-Token" validate:"required"`
+}
+
+type updateUserRequest struct {
+	ID   string `param:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+func noop(c *tong.Context) error {
+	return nil
+}
+
+// decode the document as the clients do
+func openAPIJSON(t *testing.T, app *tong.Tong) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(app.OpenAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func lookup(t *testing.T, v interface{}, keys ...string) interface{} {
+	t.Helper()
+	for _, key := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok || m[key] == nil {
+			t.Fatalf("%v not found in %v", keys, v)
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestTong_OpenAPI(t *testing.T) {
+	app := tongtest.NewApp()
+	app.GET("/users", noop).Doc("List users", "").Tag("users").
+		Reads(listUsersRequest{}).Returns(http.StatusOK, []User{})
+	app.POST("/users", noop).Tag("users").Reads(User{}).Returns(http.StatusCreated, User{}).Returns(http.StatusBadRequest, nil)
+	app.POST("/users/:id", noop).Reads(&updateUserRequest{})
+	app.GET("/internal", noop).Hide()
+	doc := openAPIJSON(t, app)
+
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v", doc["openapi"])
+	}
+	if _, ok := doc["paths"].(map[string]interface{})["/internal"]; ok {
+		t.Error("the hidden route is documented")
+	}
+
+	list := lookup(t, doc, "paths", "/users", "get")
+	if lookup(t, list, "summary") != "List users" {
+		t.Errorf("summary = %v", lookup(t, list, "summary"))
+	}
+	params := lookup(t, list, "parameters").([]interface{})
+	if len(params) != 2 {
+		t.Fatalf("parameters = %v", params)
+	}
+	page, token := params[0].(map[string]interface{}), params[1].(map[string]interface{})
+	if page["in"] != "query" || page["required"] != nil || lookup(t, page, "schema", "minimum") != 1.0 {
+		t.Errorf("page = %v", page)
+	}
+	if token["in"] != "header" || token["name"] != "X-Token" || token["required"] != true {
+		t.Errorf("token = %v", token)
+	}
+	if items := lookup(t, list, "responses", "200", "content", "application/json", "schema", "items", "$ref"); items != "#/components/schemas/User" {
+		t.Errorf("items = %v", items)
+	}
+
+	create := lookup(t, doc, "paths", "/users", "post")
+	if ref := lookup(t, create, "requestBody", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/User" {
+		t.Errorf("body = %v", ref)
+	}
+	if desc := lookup(t, create, "responses", "400", "description"); desc != "Bad Request" {
+		t.Errorf("400 = %v", desc)
+	}
+
+	user := lookup(t, doc, "components", "schemas", "User")
+	name := lookup(t, user, "properties", "name").(map[string]interface{})
+	if name["minLength"] != 2.0 || name["maxLength"] != 32.0 || name["description"] != "the display name" {
+		t.Errorf("name = %v", name)
+	}
+	if lookup(t, user, "properties", "email", "format") != "email" || len(lookup(t, user, "properties", "role", "enum").([]interface{})) != 2 {
+		t.Errorf("user = %v", user)
+	}
+	if lookup(t, user, "properties", "created_at", "format") != "date-time" {
+		t.Errorf("created_at = %v", lookup(t, user, "properties", "created_at"))
+	}
+	if _, ok := lookup(t, user, "properties").(map[string]interface{})["secret"]; ok {
+		t.Error("the unexported field is documented")
+	}
+	if required := lookup(t, user, "required").([]interface{}); len(required) != 1 || required[0] != "name" {
+		t.Errorf("required = %v", required)
+	}
+	lookup(t, doc, "components", "schemas", "Address", "properties", "city")
+
+	// the path params are parameters, and the rest is the body
+	update := lookup(t, doc, "paths", "/users/{id}", "post")
+	id := lookup(t, update, "parameters").([]interface{})[0].(map[string]interface{})
+	if id["in"] != "path" || id["name"] != "id" || id["required"] != true {
+		t.Errorf("id = %v", id)
+	}
+	body := lookup(t, update, "requestBody", "content", "application/json", "schema")
+	if _, ok := lookup(t, body, "properties").(map[string]interface{})["ID"]; ok {
+		t.Errorf("the path param is in the body: %v", body)
+	}
+}
+
+func TestTong_ServeOpenAPI(t *testing.T) {
+	app := tongtest.NewApp()
+	app.ServeOpenAPI(tong.OpenAPIConfig{Title: "Users"})
+	app.GET("/users", noop).Returns(http.StatusOK, []User{})
+	client := tongtest.NewClient(app)
+
+	var doc tong.OpenAPI
+	client.GET("/openapi.json").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&doc)
+	if doc.Info.Title != "Users" || len(doc.Paths) != 1 || doc.Paths["/users"]["get"] == nil {
+		t.Errorf("doc = %+v", doc)
+	}
+	client.GET("/docs").Do(t).ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", "text/html; charset=UTF-8").ExpectBodyContains(`const specPath = "/openapi.json"`)
+}
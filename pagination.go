@@ -0,0 +1,410 @@
+package tong
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ming3000/tong/common"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PageOptions is the config of the pagination of a list endpoint.
+type PageOptions struct {
+	// DefaultSize is the size without the size param, it defaults to 20
+	DefaultSize int
+	// MaxSize is the largest size accepted, it defaults to 100
+	MaxSize int
+	// Sorts are the fields the list can be sorted by, no sort param is accepted if empty
+	Sorts []string
+	// DefaultSort is the sort without the sort param, e.g. "-created_at,id"
+	DefaultSort string
+	// Filters are the fields the list can be filtered by, no filter param is accepted if empty
+	Filters []string
+	// Secret signs the cursors, the cursor pagination is enabled with it
+	Secret []byte
+}
+
+// SortField is a field of the sort param, "-name" is descending.
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// Filter is a filter param, filter[status]=active is the operator eq,
+// and filter[age][gte]=18 is the operator gte.
+type Filter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Values returns the comma separated values of the operator in.
+func (f Filter) Values() []string {
+	return strings.Split(f.Value, ",")
+}
+
+// the operators of the filters
+var filterOps = []string{"eq", "ne", "lt", "lte", "gt", "gte", "in", "contains"}
+
+// Page is the requested page of a list, in the offset mode by the page and size params,
+// or in the cursor mode with PageOptions.Secret, by the cursor param holding the values
+// of the sort fields of the last item of the previous page, nil on the first page.
+type Page struct {
+	// Page is the 1-based number of the page, 0 in the cursor mode
+	Page int
+	// Size is the number of the items of the page
+	Size int
+	// Offset is the number of the items before the page, in the offset mode
+	Offset int
+	// Cursor is the values of the cursor param
+	Cursor  map[string]interface{}
+	Sort    []SortField
+	Filters []Filter
+
+	context    *Context
+	options    PageOptions
+	cursorMode bool
+	total      int
+	nextCursor string
+}
+
+// IsCursor reports whether the page is in the cursor mode.
+func (p *Page) IsCursor() bool {
+	return p.cursorMode
+}
+
+// Pagination parses the page, size, cursor, sort and filter params by the options,
+// an invalid param is an HTTPError of StatusBadRequest with the validation errors as its details.
+func (c *Context) Pagination(options ...PageOptions) (*Page, error) {
+	var opts PageOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.DefaultSize <= 0 {
+		opts.DefaultSize = 20
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 100
+	}
+
+	query := c.request.URL.Query()
+	errs := make(ValidationErrors, 0)
+	fail := func(field, rule, param, message string) {
+		errs = append(errs, FieldError{Field: field, Rule: rule, Param: param, Message: message})
+	}
+	number := func(name string, defaultValue, min, max int) int {
+		s := query.Get(name)
+		if s == "" {
+			return defaultValue
+		}
+		n, err := strconv.Atoi(s)
+		switch {
+		case err != nil:
+			fail(name, "type", "integer", "must be of type integer")
+		case n < min:
+			fail(name, "min", strconv.Itoa(min), fmt.Sprintf("must be at least %d", min))
+		case max > 0 && n > max:
+			fail(name, "max", strconv.Itoa(max), fmt.Sprintf("must be at most %d", max))
+		}
+		return n
+	}
+
+	p := &Page{context: c, options: opts, total: -1}
+	p.Size = number("size", opts.DefaultSize, 1, opts.MaxSize)
+	// the largest page whose offset does not overflow
+	maxPage := math.MaxInt
+	if p.Size > 1 {
+		maxPage = math.MaxInt/p.Size + 1
+	}
+	p.Page = number("page", 1, 1, maxPage)
+	p.Offset = (p.Page - 1) * p.Size
+
+	if s := query.Get("sort"); s != "" {
+		p.Sort = parseSort(s)
+		for _, f := range p.Sort {
+			if !contains(opts.Sorts, f.Field) {
+				fail("sort", "oneof", strings.Join(opts.Sorts, " "), "must be one of "+strings.Join(opts.Sorts, " "))
+				break
+			}
+		} // for>>
+	} else if opts.DefaultSort != "" {
+		p.Sort = parseSort(opts.DefaultSort)
+	} // else>
+
+	// with the secret, the list is in the cursor mode unless the page param is given
+	cursor := query.Get("cursor")
+	if len(opts.Secret) > 0 && (cursor != "" || query.Get("page") == "") {
+		p.cursorMode = true
+		p.Page, p.Offset = 0, 0
+	}
+
+	for key, values := range query {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		f, err := parseFilter(key, values[0])
+		if err == nil && !contains(opts.Filters, f.Field) {
+			err = fmt.Errorf("must be one of %s", strings.Join(opts.Filters, " "))
+		}
+		if err != nil {
+			fail(key, "filter", "", err.Error())
+			continue
+		}
+		p.Filters = append(p.Filters, f)
+	} // for>
+	// in the order of the fields and the operators, for the stable queries
+	sort.Slice(p.Filters, func(i, j int) bool {
+		a, b := p.Filters[i], p.Filters[j]
+		return a.Field < b.Field || (a.Field == b.Field && a.Op < b.Op)
+	})
+
+	// after the sort and the filters, which the cursor is bound to
+	if cursor != "" {
+		values, err := p.decodeCursor(cursor)
+		if err != nil {
+			fail("cursor", "cursor", "", err.Error())
+		}
+		p.Cursor = values
+	} // if>
+
+	if len(errs) > 0 {
+		return nil, NewHTTPError(http.StatusBadRequest, "invalid pagination").SetDetails(errs).SetInternal(errs)
+	}
+	return p, nil
+}
+
+// "-created_at,name" is created_at descending, then name
+func parseSort(s string) []SortField {
+	fields := make([]SortField, 0)
+	for _, name := range strings.Split(s, ",") {
+		field := SortField{Field: strings.TrimSpace(name)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Field, field.Desc = field.Field[1:], true
+		}
+		fields = append(fields, field)
+	} // for>
+	return fields
+}
+
+// filter[status] or filter[age][gte]
+func parseFilter(key, value string) (Filter, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+	f := Filter{Field: parts[0], Op: "eq", Value: value}
+	if len(parts) > 2 || f.Field == "" {
+		return f, fmt.Errorf("must be like filter[field] or filter[field][op]")
+	}
+	if len(parts) == 2 {
+		f.Op = parts[1]
+		if !contains(filterOps, f.Op) {
+			return f, fmt.Errorf("operator must be one of %s", strings.Join(filterOps, " "))
+		}
+	} // if>
+	return f, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the filter of the field and the operator, and false if there is no such filter.
+func (p *Page) Filter(field, op string) (Filter, bool) {
+	for _, f := range p.Filters {
+		if f.Field == field && f.Op == op {
+			return f, true
+		}
+	}
+	return Filter{}, false
+}
+
+// $--- cursor ---
+// the cursor is the base64 of its JSON and its signature, bound to the sort and the filters
+type cursorPayload struct {
+	Sort    string                 `json:"s"`
+	Filters string                 `json:"f,omitempty"`
+	Values  map[string]interface{} `json:"v"`
+}
+
+func (p *Page) sortKey() string {
+	fields := make([]string, len(p.Sort))
+	for i, f := range p.Sort {
+		fields[i] = f.Field
+		if f.Desc {
+			fields[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// the filters are sorted, so the same filters have the same key
+func (p *Page) filterKey() string {
+	query := make([]string, len(p.Filters))
+	for i, f := range p.Filters {
+		query[i] = url.QueryEscape(f.Field+"["+f.Op+"]") + "=" + url.QueryEscape(f.Value)
+	}
+	return strings.Join(query, "&")
+}
+
+func (p *Page) sign(data []byte) string {
+	mac := hmac.New(sha256.New, p.options.Secret)
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Page) decodeCursor(cursor string) (map[string]interface{}, error) {
+	if len(p.options.Secret) == 0 {
+		return nil, fmt.Errorf("is not supported")
+	}
+	i := strings.LastIndex(cursor, ".")
+	if i < 0 {
+		return nil, fmt.Errorf("is invalid")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor[:i])
+	if err != nil || !hmac.Equal([]byte(p.sign(data)), []byte(cursor[i+1:])) {
+		return nil, fmt.Errorf("is invalid")
+	}
+	// the numbers are kept as json.Number, so the large integer IDs are exact
+	var payload cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("is invalid")
+	}
+	// a cursor of another order skips or repeats the items
+	if payload.Sort != p.sortKey() {
+		return nil, fmt.Errorf("does not match the sort")
+	}
+	if payload.Filters != p.filterKey() {
+		return nil, fmt.Errorf("does not match the filters")
+	}
+	return payload.Values, nil
+}
+
+// SetNextCursor sets the cursor of the next page, by the values of the sort fields of the last item,
+// it is left empty on the last page, e.g. when fewer than Size+1 items are found.
+func (p *Page) SetNextCursor(values map[string]interface{}) error {
+	if len(p.options.Secret) == 0 {
+		return fmt.Errorf("pagination: the cursor needs PageOptions.Secret")
+	}
+	data, err := json.Marshal(cursorPayload{Sort: p.sortKey(), Filters: p.filterKey(), Values: values})
+	if err != nil {
+		return err
+	}
+	p.nextCursor = base64.RawURLEncoding.EncodeToString(data) + "." + p.sign(data)
+	return nil
+}
+
+// SetTotal sets the number of all the items, for the last page of the offset mode.
+func (p *Page) SetTotal(total int) {
+	p.total = total
+}
+
+// $--- response ---
+// PageMeta is the pagination of an envelope.
+type PageMeta struct {
+	Page       int         `json:"page,omitempty"`
+	Size       int         `json:"size"`
+	Total      *int        `json:"total,omitempty"`
+	Pages      *int        `json:"pages,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Sort       []SortField `json:"sort,omitempty"`
+	Filters    []Filter    `json:"filters,omitempty"`
+}
+
+// PageEnvelope is the standard response of a list endpoint.
+type PageEnvelope struct {
+	Data  interface{}       `json:"data"`
+	Meta  PageMeta          `json:"meta"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// Links returns the URLs of the first, prev, next and last pages in the offset mode,
+// or the next page in the cursor mode, the other params of the request are kept.
+func (p *Page) Links() map[string]string {
+	links := make(map[string]string)
+	link := func(rel string, params map[string]string) {
+		query := p.context.request.URL.Query()
+		query.Del("page")
+		query.Del("cursor")
+		for k, v := range params {
+			query.Set(k, v)
+		}
+		u := url.URL{Path: p.context.request.URL.Path, RawQuery: query.Encode()}
+		links[rel] = u.String()
+	}
+
+	if p.cursorMode {
+		if p.Cursor != nil {
+			link("first", nil)
+		}
+		if p.nextCursor != "" {
+			link("next", map[string]string{"cursor": p.nextCursor})
+		}
+		return links
+	} // if>
+	link("first", map[string]string{"page": "1"})
+	if p.Page > 1 {
+		link("prev", map[string]string{"page": strconv.Itoa(p.Page - 1)})
+	}
+	if pages, ok := p.pages(); ok {
+		if p.Page < pages {
+			link("next", map[string]string{"page": strconv.Itoa(p.Page + 1)})
+		}
+		link("last", map[string]string{"page": strconv.Itoa(pages)})
+	} else {
+		link("next", map[string]string{"page": strconv.Itoa(p.Page + 1)})
+	} // else>
+	return links
+}
+
+// the number of the pages, false if the total is unknown
+func (p *Page) pages() (int, bool) {
+	if p.total < 0 {
+		return 0, false
+	}
+	pages := (p.total + p.Size - 1) / p.Size
+	if pages == 0 {
+		pages = 1
+	}
+	return pages, true
+}
+
+// SetLinkHeader sets the Link header of the pages, like <.../users?page=2>; rel="next".
+func (p *Page) SetLinkHeader() {
+	links := p.Links()
+	parts := make([]string, 0, len(links))
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if u, ok := links[rel]; ok {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, u, rel))
+		}
+	} // for>
+	p.context.response.Header().Set(common.HeaderLink, strings.Join(parts, ", "))
+}
+
+// Envelope returns the standard response of the items of the page.
+func (p *Page) Envelope(items interface{}) *PageEnvelope {
+	meta := PageMeta{Page: p.Page, Size: p.Size, NextCursor: p.nextCursor, Sort: p.Sort, Filters: p.Filters}
+	if pages, ok := p.pages(); ok && !p.cursorMode {
+		total := p.total
+		meta.Total, meta.Pages = &total, &pages
+	}
+	return &PageEnvelope{Data: items, Meta: meta, Links: p.Links()}
+}
+
+// Paginated sends the items of the page in the standard envelope, with the Link header.
+func (c *Context) Paginated(code int, items interface{}, p *Page) error {
+	p.SetLinkHeader()
+	return c.Json(code, p.Envelope(items), "")
+}
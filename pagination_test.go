@@ -0,0 +1,194 @@
+package tong_test
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type pagedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var pagedUsers = []pagedUser{{1, "a"}, {2, "b"}, {3, "c"}, {4, "d"}, {5, "e"}}
+
+func newPagedApp(opts tong.PageOptions) *tong.Tong {
+	app := tongtest.NewApp()
+	app.GET("/users", func(c *tong.Context) error {
+		p, err := c.Pagination(opts)
+		if err != nil {
+			return err
+		}
+		if p.IsCursor() {
+			// the items after the cursor, one more to know if there is a next page
+			start := 0
+			if p.Cursor != nil {
+				id, _ := p.Cursor["id"].(json.Number).Int64()
+				start = int(id)
+			}
+			end := start + p.Size
+			if end < len(pagedUsers) {
+				if err := p.SetNextCursor(map[string]interface{}{"id": pagedUsers[end-1].ID}); err != nil {
+					return err
+				}
+			} else {
+				end = len(pagedUsers)
+			}
+			return c.Paginated(http.StatusOK, pagedUsers[start:end], p)
+		} // if>
+		p.SetTotal(len(pagedUsers))
+		end := p.Offset + p.Size
+		if end > len(pagedUsers) {
+			end = len(pagedUsers)
+		}
+		return c.Paginated(http.StatusOK, pagedUsers[p.Offset:end], p)
+	})
+	app.GET("/params", func(c *tong.Context) error {
+		p, err := c.Pagination(opts)
+		if err != nil {
+			return err
+		}
+		f, _ := p.Filter("age", "gte")
+		return c.Json(http.StatusOK, map[string]interface{}{"sort": p.Sort, "filters": p.Filters, "age": f.Value}, "")
+	})
+	return app
+}
+
+func TestPagination_Offset(t *testing.T) {
+	client := tongtest.NewClient(newPagedApp(tong.PageOptions{DefaultSize: 2, MaxSize: 3}))
+
+	client.GET("/users").Query("page", "2").Do(t).ExpectStatus(http.StatusOK).
+		ExpectHeader("Link", `</users?page=1>; rel="first", </users?page=1>; rel="prev", </users?page=3>; rel="next", </users?page=3>; rel="last"`).
+		ExpectJSON(map[string]interface{}{
+			"data": []pagedUser{{3, "c"}, {4, "d"}},
+			"meta": map[string]int{"page": 2, "size": 2, "total": 5, "pages": 3},
+			"links": map[string]string{
+				"first": "/users?page=1", "prev": "/users?page=1", "next": "/users?page=3", "last": "/users?page=3",
+			},
+		})
+	// the other params are kept
+	client.GET("/users").Query("size", "3").Do(t).ExpectStatus(http.StatusOK).
+		ExpectHeader("Link", `</users?page=1&size=3>; rel="first", </users?page=2&size=3>; rel="next", </users?page=2&size=3>; rel="last"`)
+
+	client.GET("/users").Query("page", "0").Query("size", "4").Do(t).ExpectStatus(http.StatusBadRequest).ExpectJSON(map[string]interface{}{
+		"message": "invalid pagination",
+		"details": []map[string]string{
+			{"field": "size", "rule": "max", "param": "3", "message": "must be at most 3"},
+			{"field": "page", "rule": "min", "param": "1", "message": "must be at least 1"},
+		},
+	})
+	client.GET("/users").Query("size", "x").Do(t).ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"rule":"type"`)
+	// the offset would overflow
+	client.GET("/users").Query("page", strconv.Itoa(math.MaxInt)).Query("size", "3").Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains(`"field":"page","rule":"max"`)
+	// no secret, no cursor
+	client.GET("/users").Query("cursor", "x.y").Do(t).ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"message":"is not supported"`)
+}
+
+func TestPagination_SortFilter(t *testing.T) {
+	client := tongtest.NewClient(newPagedApp(tong.PageOptions{
+		Sorts: []string{"name", "created_at"}, DefaultSort: "-created_at", Filters: []string{"status", "age"},
+	}))
+
+	client.GET("/params").Do(t).ExpectStatus(http.StatusOK).
+		ExpectJSON(map[string]interface{}{"sort": []map[string]interface{}{{"field": "created_at", "desc": true}}, "filters": nil, "age": ""})
+	client.GET("/params").Query("sort", "name,-created_at").Query("filter[status]", "active").Query("filter[age][gte]", "18").
+		Do(t).ExpectStatus(http.StatusOK).ExpectJSON(map[string]interface{}{
+		"sort": []map[string]interface{}{{"field": "name"}, {"field": "created_at", "desc": true}},
+		"filters": []map[string]string{
+			{"field": "age", "op": "gte", "value": "18"},
+			{"field": "status", "op": "eq", "value": "active"},
+		},
+		"age": "18",
+	})
+
+	client.GET("/params").Query("sort", "password").Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains(`{"field":"sort","rule":"oneof","param":"name created_at","message":"must be one of name created_at"}`)
+	client.GET("/params").Query("filter[password]", "x").Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains(`"message":"must be one of status age"`)
+	client.GET("/params").Query("filter[age][like]", "1").Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains(`"field":"filter[age][like]"`)
+}
+
+func TestPagination_Cursor(t *testing.T) {
+	client := tongtest.NewClient(newPagedApp(tong.PageOptions{
+		DefaultSize: 2, Sorts: []string{"id", "name"}, DefaultSort: "id", Filters: []string{"name"}, Secret: []byte("secret"),
+	}))
+
+	var first tong.PageEnvelope
+	client.GET("/users").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&first)
+	cursor := first.Meta.NextCursor
+	if cursor == "" || first.Meta.Page != 0 || first.Meta.Total != nil || first.Links["next"] != "/users?cursor="+cursor {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	var second tong.PageEnvelope
+	client.GET("/users").Query("cursor", cursor).Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&second)
+	if items := second.Data.([]interface{}); len(items) != 2 || items[0].(map[string]interface{})["id"] != float64(3) {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+	if second.Links["first"] != "/users" {
+		t.Fatalf("unexpected links: %v", second.Links)
+	}
+
+	// the last page has no next cursor
+	var last tong.PageEnvelope
+	client.GET("/users").Query("cursor", second.Meta.NextCursor).Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&last)
+	if len(last.Data.([]interface{})) != 1 || last.Meta.NextCursor != "" || last.Links["next"] != "" {
+		t.Fatalf("unexpected last page: %+v", last)
+	}
+
+	// tampered, or of another sort
+	client.GET("/users").Query("cursor", "x"+cursor).Do(t).ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"message":"is invalid"`)
+	client.GET("/users").Query("cursor", cursor).Query("sort", "-id").Do(t).ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains(`"message":"does not match the sort"`)
+
+	// bound to the filters
+	var filtered tong.PageEnvelope
+	client.GET("/users").Query("filter[name]", "a").Query("size", "1").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&filtered)
+	client.GET("/users").Query("cursor", filtered.Meta.NextCursor).Query("filter[name]", "b").Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"message":"does not match the filters"`)
+	client.GET("/users").Query("cursor", filtered.Meta.NextCursor).Query("filter[name]", "a").Do(t).ExpectStatus(http.StatusOK)
+	client.GET("/users").Query("cursor", cursor).Query("filter[name]", "a").Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectBodyContains(`"message":"does not match the filters"`)
+
+	// the page param is still the offset mode
+	var page tong.PageEnvelope
+	client.GET("/users").Query("page", "3").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&page)
+	if page.Meta.Page != 3 || *page.Meta.Total != 5 || page.Links["last"] != "/users?page=3" {
+		t.Fatalf("unexpected offset page: %+v", page)
+	}
+}
+
+func TestPagination_CursorLargeID(t *testing.T) {
+	const id int64 = 9007199254740993
+	app := tongtest.NewApp()
+	app.GET("/items", func(c *tong.Context) error {
+		p, err := c.Pagination(tong.PageOptions{Sorts: []string{"id"}, DefaultSort: "id", Secret: []byte("secret")})
+		if err != nil {
+			return err
+		}
+		if p.Cursor != nil {
+			got, err := p.Cursor["id"].(json.Number).Int64()
+			if err != nil || got != id {
+				t.Errorf("cursor id = %v, %v", p.Cursor["id"], err)
+			}
+			return c.Paginated(http.StatusOK, []int64{}, p)
+		} // if>
+		if err := p.SetNextCursor(map[string]interface{}{"id": id}); err != nil {
+			return err
+		}
+		return c.Paginated(http.StatusOK, []int64{id}, p)
+	})
+	client := tongtest.NewClient(app)
+
+	var first tong.PageEnvelope
+	client.GET("/items").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&first)
+	client.GET("/items").Query("cursor", first.Meta.NextCursor).Do(t).ExpectStatus(http.StatusOK)
+}
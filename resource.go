@@ -0,0 +1,166 @@
+package tong
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var handlerFuncType = reflect.TypeOf(HandlerFunc(nil))
+
+// resourceRoutes are the conventional methods of a resource, and their routes
+var resourceRoutes = []struct {
+	name, method, path string
+}{
+	{"Index", http.MethodGet, ""},
+	{"Create", http.MethodPost, ""},
+	{"Show", http.MethodGet, "/:id"},
+	{"Update", http.MethodPut, "/:id"},
+	{"Patch", http.MethodPatch, "/:id"},
+	{"Delete", http.MethodDelete, "/:id"},
+}
+
+// Resource registers the conventional methods of the controller as the REST routes of prefix:
+//
+//	Index   GET    /users
+//	Create  POST   /users
+//	Show    GET    /users/:id
+//	Update  PUT    /users/:id
+//	Patch   PATCH  /users/:id
+//	Delete  DELETE /users/:id
+//
+// the methods are HandlerFunc like func(c *Context) error, the missing ones are skipped.
+func (t *Tong) Resource(prefix string, controller interface{}, m ...MiddlewareFunc) []*RouteInfo {
+	v := reflect.ValueOf(controller)
+	routes := make([]*RouteInfo, 0, len(resourceRoutes))
+	for _, rr := range resourceRoutes {
+		method := v.MethodByName(rr.name)
+		if !method.IsValid() {
+			continue
+		}
+		h, ok := asHandler(method)
+		if !ok {
+			panic(fmt.Sprintf("tong: %s.%s is not a HandlerFunc", controllerName(v), rr.name))
+		}
+		r := t.Add(rr.method, joinPath(prefix, rr.path), h, m...)
+		r.Name = controllerName(v) + "." + rr.name
+		routes = append(routes, r)
+	} // for>
+	return routes
+}
+
+// RouteAnnotator is implemented by the controllers annotating the routes of their methods,
+// the routes are keyed by the method names, like {"Login": "POST /login"}.
+type RouteAnnotator interface {
+	Routes() map[string]string
+}
+
+// Controller registers the exported HandlerFunc methods of the controller under prefix,
+// by the annotations of RouteAnnotator, or by the convention of their names,
+// the method is the verb prefix and the path is the rest in kebab case:
+//
+//	GetProfile      GET  /prefix/profile
+//	PostResetToken  POST /prefix/reset-token
+//	Get             GET  /prefix
+//
+// the other methods are skipped.
+func (t *Tong) Controller(prefix string, controller interface{}, m ...MiddlewareFunc) []*RouteInfo {
+	v := reflect.ValueOf(controller)
+	annotations := map[string]string{}
+	if annotator, ok := controller.(RouteAnnotator); ok {
+		annotations = annotator.Routes()
+	}
+	for name := range annotations {
+		if !v.MethodByName(name).IsValid() {
+			panic(fmt.Sprintf("tong: %s has no method %s", controllerName(v), name))
+		}
+	} // for>
+
+	routes := make([]*RouteInfo, 0)
+	for i := 0; i < v.NumMethod(); i++ {
+		name := v.Type().Method(i).Name
+		var method, path string
+		if annotation, ok := annotations[name]; ok {
+			fields := strings.Fields(annotation)
+			if len(fields) != 2 {
+				panic(fmt.Sprintf("tong: invalid route %q of %s.%s", annotation, controllerName(v), name))
+			}
+			method, path = strings.ToUpper(fields[0]), fields[1]
+		} else if method, path = conventionalRoute(name); method == "" {
+			continue
+		} // else>>
+
+		h, ok := asHandler(v.Method(i))
+		if !ok {
+			if _, annotated := annotations[name]; annotated {
+				panic(fmt.Sprintf("tong: %s.%s is not a HandlerFunc", controllerName(v), name))
+			}
+			continue
+		} // if>>
+		r := t.Add(method, joinPath(prefix, path), h, m...)
+		r.Name = controllerName(v) + "." + name
+		routes = append(routes, r)
+	} // for>
+	return routes
+}
+
+var conventionalMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// the route of a method named like GetProfile, or "" if it is not named so
+func conventionalRoute(name string) (string, string) {
+	for _, method := range conventionalMethods {
+		verb := method[:1] + strings.ToLower(method[1:])
+		rest := strings.TrimPrefix(name, verb)
+		if rest == name || (rest != "" && !unicode.IsUpper(rune(rest[0]))) {
+			continue
+		}
+		return method, kebabCase(rest)
+	} // for>
+	return "", ""
+}
+
+// ResetToken is reset-token
+func kebabCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// a word starts, but not in the middle of an acronym like ID
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	} // for>
+	return b.String()
+}
+
+func asHandler(method reflect.Value) (HandlerFunc, bool) {
+	if !method.Type().ConvertibleTo(handlerFuncType) {
+		return nil, false
+	}
+	return method.Convert(handlerFuncType).Interface().(HandlerFunc), true
+}
+
+// the name of the controller type, without the pointer
+func controllerName(v reflect.Value) string {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// joinPath joins prefix and path, "/users" and ":id" are "/users/:id"
+func joinPath(prefix, path string) string {
+	p := strings.TrimRight(prefix, "/") + "/" + strings.Trim(path, "/")
+	if p != "/" {
+		p = strings.TrimRight(p, "/")
+	}
+	return fixPath(p)
+}
@@ -0,0 +1,83 @@
+package tong_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+type userController struct{}
+
+func (u *userController) Index(c *tong.Context) error {
+	return c.String(http.StatusOK, "index")
+}
+
+func (u *userController) Show(c *tong.Context) error {
+	return c.String(http.StatusOK, "show "+c.Param("id"))
+}
+
+func (u *userController) Update(c *tong.Context) error {
+	return c.String(http.StatusOK, "update "+c.Param("id"))
+}
+
+func (u *userController) Delete(c *tong.Context) error {
+	return c.String(http.StatusOK, "delete "+c.Param("id"))
+}
+
+func TestTong_Resource(t *testing.T) {
+	app := tongtest.NewApp()
+	routes := app.Resource("/users/", &userController{})
+	if len(routes) != 4 || routes[1].Name != "userController.Show" || routes[1].Path != "/users/:id" {
+		t.Fatalf("routes = %+v", routes)
+	}
+
+	client := tongtest.NewClient(app)
+	client.GET("/users").Do(t).ExpectStatus(http.StatusOK).ExpectBody("index")
+	client.GET("/users/7").Do(t).ExpectStatus(http.StatusOK).ExpectBody("show 7")
+	client.NewRequest(http.MethodPut, "/users/7").Do(t).ExpectStatus(http.StatusOK).ExpectBody("update 7")
+	client.NewRequest(http.MethodDelete, "/users/7").Do(t).ExpectStatus(http.StatusOK).ExpectBody("delete 7")
+	// no Create
+	client.POST("/users").Do(t).ExpectBody("handler not found")
+}
+
+type authController struct{}
+
+func (a authController) Routes() map[string]string {
+	return map[string]string{"Login": "POST /login", "Who": "get /whoami/:id"}
+}
+
+func (a authController) Login(c *tong.Context) error {
+	return c.String(http.StatusOK, "login")
+}
+
+func (a authController) Who(c *tong.Context) error {
+	return c.String(http.StatusOK, "who "+c.Param("id"))
+}
+
+func (a authController) PostResetToken(c *tong.Context) error {
+	return c.String(http.StatusOK, "reset")
+}
+
+func (a authController) Get(c *tong.Context) error {
+	return c.String(http.StatusOK, "auth")
+}
+
+// not a handler
+func (a authController) GetName() string { return "auth" }
+
+func TestTong_Controller(t *testing.T) {
+	app := tongtest.NewApp()
+	routes := app.Controller("/auth", authController{})
+	if len(routes) != 4 {
+		t.Fatalf("routes = %+v", routes)
+	}
+
+	client := tongtest.NewClient(app)
+	client.POST("/auth/login").Do(t).ExpectStatus(http.StatusOK).ExpectBody("login")
+	client.GET("/auth/whoami/tom").Do(t).ExpectStatus(http.StatusOK).ExpectBody("who tom")
+	client.POST("/auth/reset-token").Do(t).ExpectStatus(http.StatusOK).ExpectBody("reset")
+	client.GET("/auth").Do(t).ExpectStatus(http.StatusOK).ExpectBody("auth")
+	client.GET("/auth/name").Do(t).ExpectBody("handler not found")
+}
@@ -0,0 +1,43 @@
+package tong_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ming3000/tong"
+)
+
+func TestResponse_Write(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := tong.NewResponse(w)
+	r.WriteHeader(http.StatusCreated)
+	// the status is only sent once
+	r.WriteHeader(http.StatusOK)
+	n, err := r.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatal(n, err)
+	}
+	_, _ = r.Write([]byte(" world"))
+
+	if w.Code != http.StatusCreated || r.Status != http.StatusCreated || r.Size != 11 {
+		t.Fatal("unexpected response", w.Code, r.Status, r.Size)
+	}
+	if w.Body.String() != "hello world" {
+		t.Fatal("unexpected body", w.Body.String())
+	}
+
+	r.Reset(httptest.NewRecorder())
+	if r.Status != http.StatusOK || r.Size != 0 || r.IfHeaderBeenSet {
+		t.Fatal("response should be reset", r)
+	}
+}
+
+func TestResponse_WriteDefaultStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := tong.NewResponse(w)
+	_, _ = r.Write([]byte("ok"))
+	if w.Code != http.StatusOK || !r.IfHeaderBeenSet {
+		t.Fatal("write should send the default status", w.Code)
+	}
+}
@@ -1,15 +1,30 @@
 package tong
 
-import "net/http"
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
 
-// RouteInfo is detail info of request.
+// RouteInfo is detail info of request,
+// with the optional metadata for the OpenAPI document.
 type RouteInfo struct {
-	Method string
-	Path   string
-	Name   string
+	Method      string
+	Path        string
+	Name        string
+	Summary     string
+	Description string
+	Tags        []string
+	Request     reflect.Type
+	Responses   map[int]reflect.Type
+	Params      []ParamInfo
+	Hidden      bool
+	// the names of the route middleware
+	Middleware []string
 }
 
-// Router is for request matching.
+// Router is for request matching,
+// a segment of the path is static, or a param like :id matching any segment.
 type Router struct {
 	root   *treeNode
 	routes map[string]*RouteInfo
@@ -24,77 +39,119 @@ func NewRouter() *Router {
 }
 
 // Add registers a route for method and path with matching handler.
-func (r *Router) Add(method, path string, h HandlerFunc) {
-	r.root.insert(method, fixPath(path), h)
+func (r *Router) Add(method, path string, h HandlerFunc) *RouteInfo {
+	path = fixPath(path)
+	r.root.insert(method, path, h)
+	info := &RouteInfo{Method: method, Path: path}
+	r.routes[method+path] = info
+	return info
 }
 
-// Find a handler registered for method and path.
+// Find a handler registered for method and path,
+// the context gets the route path and the params.
 func (r *Router) Find(method, path string, ctx *Context) {
-	h := r.root.search(method, fixPath(path))
+	ctx.paramNames, ctx.paramValues = ctx.paramNames[:0], ctx.paramValues[:0]
+	node, values := r.root.search(method, splitPath(fixPath(path)), nil)
+	h := node.findHandler(method)
+	if h == nil {
+		ctx.handler = NotFoundHandler
+		ctx.path = ""
+		return
+	}
 	ctx.handler = h
+	ctx.path = node.path
+	ctx.paramNames = append(ctx.paramNames, node.paramNames...)
+	ctx.paramValues = append(ctx.paramValues, values...)
+}
+
+// Lookup returns the route matching method and path, and the values of its params.
+func (r *Router) Lookup(method, path string) (*RouteInfo, map[string]string) {
+	node, values := r.root.search(method, splitPath(fixPath(path)), nil)
+	if node.findHandler(method) == nil {
+		return nil, nil
+	}
+	params := make(map[string]string, len(values))
+	for i, v := range values {
+		params[node.paramNames[i]] = v
+	}
+	return r.routes[method+node.path], params
 }
 
-type methodHandler struct {
-	get  HandlerFunc
-	post HandlerFunc
+// "/users/:id" is ["users", ":id"], and "/" is []
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
 }
 
+type methodHandler map[string]HandlerFunc
+
 type treeNode struct {
-	next          [128]*treeNode
-	methodHandler *methodHandler
+	static        map[string]*treeNode
+	param         *treeNode
+	methodHandler methodHandler
+	// the route path and its param names, of the nodes with handlers
+	path       string
+	paramNames []string
 }
 
 /** Initialize your data structure here. */
 func newTreeNode() *treeNode {
-	return &treeNode{next: [128]*treeNode{}, methodHandler: new(methodHandler)}
+	return &treeNode{static: map[string]*treeNode{}, methodHandler: methodHandler{}}
 }
 
-/** inserts a path & handler into the trie. */
+/** inserts a path & handler into the tree. */
 func (t *treeNode) insert(method, path string, hand HandlerFunc) {
 	cur := t
-	for _, v := range path {
-		if cur.next[v] == nil {
-			cur.next[v] = newTreeNode()
+	names := make([]string, 0)
+	for _, s := range splitPath(path) {
+		if strings.HasPrefix(s, ":") {
+			if cur.param == nil {
+				cur.param = newTreeNode()
+			} // if>>>
+			names = append(names, s[1:])
+			cur = cur.param
+			continue
 		} // if>>
-		cur = cur.next[v]
-	} // for>
-	if cur != nil {
-		cur.addHandler(method, hand)
-	} // if>
-}
-
-/** returns handler if the path is in the trie. */
-func (t *treeNode) search(method, path string) HandlerFunc {
-	cur := t
-	for _, v := range path {
-		if cur.next[v] == nil {
-			return NotFoundHandler
+		if cur.static[s] == nil {
+			cur.static[s] = newTreeNode()
 		} // if>>
-		cur = cur.next[v]
+		cur = cur.static[s]
 	} // for>
-	if cur != nil {
-		return cur.findHandler(method)
-	} else {
-		return NotFoundHandler
-	} // else>
+	cur.path = path
+	cur.paramNames = names
+	cur.methodHandler[method] = hand
 }
 
-func (t *treeNode) addHandler(method string, h HandlerFunc) {
-	switch method {
-	case http.MethodGet:
-		t.methodHandler.get = h
-	case http.MethodPost:
-		t.methodHandler.post = h
+/** returns the node of the segments with a handler of the method and the param values, or nil. */
+func (t *treeNode) search(method string, segments []string, values []string) (*treeNode, []string) {
+	if len(segments) == 0 {
+		if t.findHandler(method) == nil {
+			return nil, nil
+		}
+		return t, values
 	}
+	// the static segments win, then backtrack to the params
+	if next := t.static[segments[0]]; next != nil {
+		if node, vs := next.search(method, segments[1:], values); node != nil {
+			return node, vs
+		} // if>>
+	} // if>
+	if t.param != nil && segments[0] != "" {
+		value, err := url.PathUnescape(segments[0])
+		if err != nil {
+			value = segments[0]
+		}
+		return t.param.search(method, segments[1:], append(values, value))
+	} // if>
+	return nil, nil
 }
 
 func (t *treeNode) findHandler(method string) HandlerFunc {
-	switch method {
-	case http.MethodGet:
-		return t.methodHandler.get
-	case http.MethodPost:
-		return t.methodHandler.post
-	default:
-		return NotFoundHandler
+	if t == nil {
+		return nil
 	}
+	return t.methodHandler[method]
 }
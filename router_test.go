@@ -0,0 +1,90 @@
+package tong_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestRouter_Find(t *testing.T) {
+	router := tong.NewRouter()
+	router.Add(http.MethodGet, "users", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "users")
+	})
+	c, w := tongtest.NewContext(tongtest.NewApp(), tongtest.NewRequest(http.MethodGet, "/users", nil))
+
+	// the path is fixed with a leading slash
+	router.Find(http.MethodGet, "/users", c)
+	if c.Path() != "/users" {
+		t.Fatal("unexpected path", c.Path())
+	}
+	if err := c.Handler()(c); err != nil || w.Body.String() != "users" {
+		t.Fatal("unexpected handler", err, w.Body.String())
+	}
+
+	router.Find(http.MethodPost, "/users", c)
+	if err := c.Handler()(c); err == nil {
+		t.Fatal("unknown method should not be found")
+	}
+	router.Find(http.MethodGet, "/user", c)
+	if err := c.Handler()(c); err == nil {
+		t.Fatal("prefix of a route should not be found")
+	}
+	// the path is matched by its segments, so a trailing slash is ignored
+	router.Find(http.MethodGet, "/users/", c)
+	if c.Path() != "/users" {
+		t.Fatal("trailing slash should match the route", c.Path())
+	}
+}
+
+func TestRouter_Params(t *testing.T) {
+	app := tongtest.NewApp()
+	reply := func(name string) tong.HandlerFunc {
+		return func(c *tong.Context) error {
+			return c.String(http.StatusOK, name+" "+c.Path()+" "+c.Param("id")+" "+c.Param("tag"))
+		}
+	}
+	app.GET("/users/:id", reply("show"))
+	app.GET("/users/new", reply("new"))
+	app.GET("/users/:id/tags/:tag", reply("tag"))
+	app.GET("/users/new/tags/all", reply("all"))
+	app.Add(http.MethodDelete, "/users/:id", reply("delete"))
+	client := tongtest.NewClient(app)
+
+	client.GET("/users/42").Do(t).ExpectBody("show /users/:id 42 ")
+	client.GET("/users/new").Do(t).ExpectBody("new /users/new  ")
+	client.GET("/users/a%20b").Do(t).ExpectBody("show /users/:id a b ")
+	client.NewRequest(http.MethodDelete, "/users/7").Do(t).ExpectBody("delete /users/:id 7 ")
+	// the static segments win, and the params are tried when they fail
+	client.GET("/users/new/tags/go").Do(t).ExpectBody("tag /users/:id/tags/:tag new go")
+	client.GET("/users/new/tags/all").Do(t).ExpectBody("all /users/new/tags/all  ")
+	// the static route of another method backtracks to the params
+	client.NewRequest(http.MethodDelete, "/users/new").Do(t).ExpectBody("delete /users/:id new ")
+	client.GET("/users/").Do(t).ExpectBody("handler not found")
+	client.GET("/users/42/tags").Do(t).ExpectBody("handler not found")
+}
+
+func TestRouter_Lookup(t *testing.T) {
+	router := tong.NewRouter()
+	router.Add(http.MethodPut, "/orders/:order/items/:item", func(c *tong.Context) error { return nil })
+
+	route, params := router.Lookup(http.MethodPut, "/orders/1/items/2")
+	if route == nil || route.Path != "/orders/:order/items/:item" || params["order"] != "1" || params["item"] != "2" {
+		t.Fatalf("route = %+v, params = %v", route, params)
+	}
+	if route, _ := router.Lookup(http.MethodGet, "/orders/1/items/2"); route != nil {
+		t.Errorf("unknown method found %+v", route)
+	}
+
+	router.Add(http.MethodGet, "/orders/new", func(c *tong.Context) error { return nil })
+	router.Add(http.MethodPost, "/orders/:order", func(c *tong.Context) error { return nil })
+	route, params = router.Lookup(http.MethodPost, "/orders/new")
+	if route == nil || route.Path != "/orders/:order" || params["order"] != "new" {
+		t.Fatalf("route = %+v, params = %v", route, params)
+	}
+	if route, _ := router.Lookup(http.MethodGet, "/orders/new"); route == nil || route.Path != "/orders/new" {
+		t.Errorf("route = %+v", route)
+	}
+}
@@ -0,0 +1,481 @@
+package tong
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// $--- schema define ---
+// Schema is the subset of JSON Schema used by the OpenAPI document.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Types                []string           `json:"-"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+}
+
+// MarshalJSON writes the types as a string, or an array for a nullable schema.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	v := struct {
+		Type interface{} `json:"type,omitempty"`
+		*plain
+	}{plain: (*plain)(s)}
+	switch len(s.Types) {
+	case 0:
+	case 1:
+		v.Type = s.Types[0]
+	default:
+		v.Type = s.Types
+	}
+	return json.Marshal(v)
+}
+
+// Type returns the first type of the schema.
+func (s *Schema) Type() string {
+	if len(s.Types) == 0 {
+		return ""
+	}
+	return s.Types[0]
+}
+
+// $--- validate tag ---
+// ValidateRule is a rule of the validate tag, e.g. `validate:"required,min=1,oneof=a b"`.
+type ValidateRule struct {
+	Name  string
+	Param string
+}
+
+// ParseValidateTag returns the rules of the validate tag,
+// a pattern may contain commas, so it is the last rule and takes the rest of the tag.
+func ParseValidateTag(tag string) []ValidateRule {
+	rules := make([]ValidateRule, 0)
+	parts := strings.Split(tag, ",")
+	for i, s := range parts {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		rule := ValidateRule{Name: s}
+		if j := strings.Index(s, "="); j >= 0 {
+			rule.Name, rule.Param = s[:j], s[j+1:]
+		}
+		if rule.Name == "pattern" {
+			rule.Param = strings.Join(append([]string{rule.Param}, parts[i+1:]...), ",")
+			return append(rules, rule)
+		}
+		rules = append(rules, rule)
+	} // for>
+	return rules
+}
+
+func hasRule(rules []ValidateRule, name string) bool {
+	for _, r := range rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// $--- reflection ---
+// the binding tags, a field of the request is read from one of them
+var bindingTags = []string{"param", "query", "header", "form", "json"}
+
+// FieldName returns the name of the field in the tag, or "" if it is skipped.
+func FieldName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" || field.PkgPath != "" {
+		return ""
+	}
+	if name == "" && tag == "json" {
+		// an untagged field is a JSON field, as encoding/json does
+		for _, other := range bindingTags {
+			if field.Tag.Get(other) != "" {
+				return ""
+			}
+		}
+		return field.Name
+	}
+	return name
+}
+
+// SchemaRegistry builds the schemas of Go types,
+// the named structs are collected as components and referred to.
+type SchemaRegistry struct {
+	Schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{Schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the schema of the type.
+func (r *SchemaRegistry) Schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	s := r.schema(t)
+	if nullable && s.Ref == "" && len(s.Types) == 1 {
+		s.Types = append(s.Types, "null")
+	}
+	return s
+}
+
+func (r *SchemaRegistry) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Types: []string{"string"}, Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Types: []string{"string"}, Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Types: []string{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Types: []string{"integer"}, Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Types: []string{"integer"}, Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Types: []string{"number"}, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Types: []string{"number"}, Format: "double"}
+	case reflect.String:
+		return &Schema{Types: []string{"string"}}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Types: []string{"array"}, Items: r.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Types: []string{"object"}, AdditionalProperties: r.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.Object(t, "json")
+		}
+		return r.ref(t)
+	default:
+		// interface{} and the others accept any value
+		return &Schema{}
+	}
+}
+
+// ref returns a reference to the component of the named struct
+func (r *SchemaRegistry) ref(t reflect.Type) *Schema {
+	name, ok := r.names[t]
+	if !ok {
+		name = t.Name()
+		for i := 2; r.Schemas[name] != nil; i++ {
+			name = t.Name() + strconv.Itoa(i)
+		}
+		r.names[t] = name
+		// reserve the name before the fields, for the recursive types
+		r.Schemas[name] = &Schema{}
+		*r.Schemas[name] = *r.Object(t, "json")
+	} // if>
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Object returns the object schema of the struct fields named by the tag,
+// the fields of the embedded structs are promoted.
+func (r *SchemaRegistry) Object(t reflect.Type, tag string) *Schema {
+	s := &Schema{Types: []string{"object"}, Properties: make(map[string]*Schema)}
+	r.walkFields(t, func(field reflect.StructField) {
+		name := FieldName(field, tag)
+		if name == "" {
+			return
+		}
+		s.Properties[name] = r.Field(field)
+		if hasRule(ParseValidateTag(field.Tag.Get("validate")), "required") {
+			s.Required = append(s.Required, name)
+		}
+	})
+	return s
+}
+
+// Field returns the schema of the field, with the rules of its validate tag.
+func (r *SchemaRegistry) Field(field reflect.StructField) *Schema {
+	s := r.Schema(field.Type)
+	if s.Ref != "" {
+		return s
+	}
+	s.Description = field.Tag.Get("description")
+	kind := s.Type()
+	for _, rule := range ParseValidateTag(field.Tag.Get("validate")) {
+		n, err := strconv.ParseFloat(rule.Param, 64)
+		switch {
+		case rule.Name == "email":
+			s.Format = "email"
+		case rule.Name == "url":
+			s.Format = "uri"
+		case rule.Name == "pattern":
+			s.Pattern = rule.Param
+		case rule.Name == "oneof":
+			for _, v := range strings.Fields(rule.Param) {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && (kind == "integer" || kind == "number") {
+					s.Enum = append(s.Enum, f)
+				} else {
+					s.Enum = append(s.Enum, v)
+				} // else>>>
+			} // for>>
+		case err != nil:
+		case kind == "string" && (rule.Name == "min" || rule.Name == "len"):
+			s.MinLength = intPtr(int(n))
+			if rule.Name == "len" {
+				s.MaxLength = intPtr(int(n))
+			}
+		case kind == "string" && rule.Name == "max":
+			s.MaxLength = intPtr(int(n))
+		case kind == "array" && rule.Name == "min":
+			s.MinItems = intPtr(int(n))
+		case kind == "array" && rule.Name == "max":
+			s.MaxItems = intPtr(int(n))
+		case rule.Name == "min":
+			s.Minimum = &n
+		case rule.Name == "max":
+			s.Maximum = &n
+		}
+	} // for>
+	return s
+}
+
+func (r *SchemaRegistry) walkFields(t reflect.Type, fn func(field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && ft.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			r.walkFields(ft, fn)
+			continue
+		}
+		fn(field)
+	} // for>
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// UnmarshalJSON reads the type as a string or an array of them.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	v := struct {
+		Type interface{} `json:"type"`
+		*plain
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.Type.(type) {
+	case string:
+		s.Types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				s.Types = append(s.Types, name)
+			}
+		}
+	}
+	return nil
+}
+
+// $--- schema validation ---
+// ValidateValue checks the decoded JSON value against the schema,
+// the failures are appended to errs, named after the field and the keyword.
+//
+// the supported keywords are $ref into the components, type, enum, format,
+// minimum, maximum, minLength, maxLength, pattern, minItems, maxItems,
+// items, required, properties and additionalProperties as a schema.
+func (doc *OpenAPI) ValidateValue(field string, s *Schema, value interface{}, errs *ValidationErrors) {
+	s = doc.Resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(rule, param, message string) {
+		*errs = append(*errs, FieldError{Field: field, Rule: rule, Param: param, Message: message})
+	}
+
+	kind := jsonKind(value)
+	if len(s.Types) > 0 && !typeAllowed(s.Types, kind) {
+		fail("type", strings.Join(s.Types, ","), "must be of type "+strings.Join(s.Types, " or "))
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "", "must be one of the enum values")
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		n := float64(utf8.RuneCountInString(v))
+		if s.MinLength != nil && n < float64(*s.MinLength) {
+			fail("minLength", strconv.Itoa(*s.MinLength), fmt.Sprintf("must be at least %d characters", *s.MinLength))
+		}
+		if s.MaxLength != nil && n > float64(*s.MaxLength) {
+			fail("maxLength", strconv.Itoa(*s.MaxLength), fmt.Sprintf("must be at most %d characters", *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			if message := checkRule(reflect.ValueOf(v), ValidateRule{Name: "pattern", Param: s.Pattern}); message != "" {
+				fail("pattern", s.Pattern, message)
+			}
+		}
+		if message := checkFormat(s.Format, v); message != "" {
+			fail("format", s.Format, message)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("minimum", formatFloat(*s.Minimum), "must be at least "+formatFloat(*s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("maximum", formatFloat(*s.Maximum), "must be at most "+formatFloat(*s.Maximum))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("minItems", strconv.Itoa(*s.MinItems), fmt.Sprintf("must be at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("maxItems", strconv.Itoa(*s.MaxItems), fmt.Sprintf("must be at most %d items", *s.MaxItems))
+		}
+		for i, item := range v {
+			doc.ValidateValue(field+"["+strconv.Itoa(i)+"]", s.Items, item, errs)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Field: joinField(field, name), Rule: "required", Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ps, ok := s.Properties[key]; ok {
+				doc.ValidateValue(joinField(field, key), ps, v[key], errs)
+			} else {
+				doc.ValidateValue(joinField(field, key), s.AdditionalProperties, v[key], errs)
+			}
+		} // for>>
+	}
+}
+
+// Resolve follows the references into the components of the document.
+func (doc *OpenAPI) Resolve(s *Schema) *Schema {
+	for i := 0; s != nil && s.Ref != "" && i < 32; i++ {
+		s = doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// Coerce converts the string values of a parameter to the type of its schema,
+// the values not converted are kept as strings, to fail the validation.
+func (doc *OpenAPI) Coerce(s *Schema, values []string) interface{} {
+	s = doc.Resolve(s)
+	if s == nil || len(values) == 0 {
+		return nil
+	}
+	switch s.Type() {
+	case "array":
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = doc.Coerce(s.Items, []string{v})
+		}
+		return items
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(values[0], 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(values[0]); err == nil {
+			return b
+		}
+	}
+	return values[0]
+}
+
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func jsonKind(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
+
+func typeAllowed(types []string, kind string) bool {
+	for _, t := range types {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkFormat(format, value string) string {
+	switch format {
+	case "email":
+		return checkRule(reflect.ValueOf(value), ValidateRule{Name: "email"})
+	case "uri":
+		return checkRule(reflect.ValueOf(value), ValidateRule{Name: "url"})
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
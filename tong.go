@@ -3,12 +3,16 @@ package tong
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ming3000/tong/common"
 	"net"
 	"net/http"
+	"os"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,14 +32,44 @@ var NotFoundHandler = func(c *Context) error {
 }
 
 // DefaultHTTPErrorHandler the default HTTP error handler.
-// it sends a string response with status code StatusInternalServerError.
+// it renders an HTTPError with its code and message, and logs its internal error,
+// and sends the other errors as a string response with status code StatusInternalServerError.
+// With app.Debug, the server errors are rendered as the debug page for the clients accepting HTML or JSON.
+// The messages are translated with the localizer of the request, see Context.SetLocalizer.
 var DefaultHTTPErrorHandler = func(c *Context, err error) {
-	_ = c.String(http.StatusInternalServerError, err.Error())
+	var he *HTTPError
+	isHTTPError := errors.As(err, &he)
+	code := http.StatusInternalServerError
+	if isHTTPError {
+		code = he.Code
+		if he.Internal != nil {
+			c.Logger().With("error", he.Internal.Error()).Warn(he.Message)
+		}
+	} // if>
+	if c.Request().Method == http.MethodHead {
+		c.Response().WriteHeader(code)
+		return
+	}
+	// the debug page of the server errors, never without app.Debug
+	if c.tong != nil && c.tong.Debug && code >= http.StatusInternalServerError && renderDebug(c, code, err) {
+		return
+	}
+	if !isHTTPError {
+		_ = c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	// the messages in the locale of the request
+	he = c.localizeHTTPError(he)
+	if c.Negotiate(common.MIMEApplicationJSON, common.MIMEApplicationXML, common.MIMETextXML, common.MIMETextPlain) == common.MIMETextPlain {
+		_ = c.String(he.Code, he.Message)
+		return
+	}
+	_ = c.Render(he.Code, he)
 }
 
 // $--- utils func ---
 // reflect name of HandlerFunc
-func handlerName(h HandlerFunc) string {
+func handlerName(h interface{}) string {
 	t := reflect.ValueOf(h).Type()
 	if t.Kind() == reflect.Func {
 		return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
@@ -58,11 +92,30 @@ type Tong struct {
 	sysMiddleware      []MiddlewareFunc
 	customerMiddleware []MiddlewareFunc
 	cronList           []*common.Cron
+	CronLocker         common.Locker
+	CronHistory        common.HistoryStore
+	Clock              common.Clock
+	TaskOptions        common.TaskQueueOptions
+	tasks              *common.TaskQueue
+	tasksOnce          sync.Once
 	pool               sync.Pool
 	Debug              bool
+	DebugRedactor      *common.Redactor
 	Logger             *common.Logger
 	NotFoundHandler    HandlerFunc
 	HTTPErrorHandler   ErrorHandlerFunc
+	config             *Config
+	configMiddleware   atomic.Value
+	configWatcher      *ConfigWatcher
+	openAPIConfig      OpenAPIConfig
+	container          *Container
+	containerOnce      sync.Once
+	shutdownHooks      []func() error
+	hookLock           sync.Mutex
+	tlsCertFile        string
+	tlsKeyFile         string
+	trustedProxies     []*net.IPNet
+	liveReloadScript   []byte
 }
 
 // New creates an instance of Wu
@@ -76,7 +129,9 @@ func New() *Tong {
 	tong.pool.New = func() interface{} {
 		return tong.NewContext(nil, nil)
 	}
-	tong.Debug = true
+	// the debug page shows the source and the request, so it is opted in
+	tong.Debug = false
+	tong.DebugRedactor = NewDebugRedactor()
 	tong.Logger = common.NewDefaultLogger(tong.Debug)
 	tong.NotFoundHandler = NotFoundHandler
 	tong.HTTPErrorHandler = DefaultHTTPErrorHandler
@@ -88,16 +143,31 @@ func New() *Tong {
 func (t *Tong) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// acquire context instance
 	c := t.pool.Get().(*Context)
-	c.Reset(r, w, c.logger, common.NewDefaultLRUCache())
+	c.Reset(r, w, t.Logger, common.NewDefaultLRUCache())
+	c.requestID = r.Header.Get(common.HeaderXRequestID)
+	if !validRequestID(c.requestID) {
+		c.requestID = newRequestID()
+	}
+	c.response.Header().Set(common.HeaderXRequestID, c.requestID)
+	c.logger = t.Logger.With("request_id", c.requestID, "method", r.Method, "client_ip", c.RealIP())
+
+	// inject the live reload script into the HTML responses
+	var reload *liveReloadWriter
+	if t.Debug && t.liveReloadScript != nil {
+		reload = &liveReloadWriter{ResponseWriter: w, script: t.liveReloadScript}
+		c.response.Writer = reload
+	}
 
 	h := NotFoundHandler
 	if t.sysMiddleware == nil {
 		t.router.Find(r.Method, parsePath(r), c)
+		c.AddLogFields("route", c.Path())
 		h = c.Handler()
 		h = prependMiddleware(h, t.customerMiddleware...)
 	} else {
 		h = func(c *Context) error {
 			t.router.Find(r.Method, parsePath(r), c)
+			c.AddLogFields("route", c.Path())
 			h := c.Handler()
 			h = prependMiddleware(h, t.customerMiddleware...)
 			return h(c)
@@ -109,6 +179,11 @@ func (t *Tong) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := h(c); err != nil {
 		t.HTTPErrorHandler(c, err)
 	}
+	// close the request scoped values
+	c.closeScope()
+	if reload != nil {
+		reload.finish()
+	}
 
 	// Release context
 	t.pool.Put(c)
@@ -120,11 +195,38 @@ func (t *Tong) Start(address string) error {
 	return t.StartServer(t.Server)
 }
 
+// StartTLS starts an HTTPS server.
+func (t *Tong) StartTLS(address, certFile, keyFile string) error {
+	t.Server.Addr = address
+	t.tlsCertFile, t.tlsKeyFile = certFile, keyFile
+	return t.StartServer(t.Server)
+}
+
+// Run starts the server at the address of the server,
+// with TLS if the cert and key files are configured.
+func (t *Tong) Run() error {
+	return t.StartServer(t.Server)
+}
+
 // Close immediately stops the server.
 func (t *Tong) Close() error {
 	// stop all cron jobs
 	t.stopCronJobs()
-	return t.Server.Close()
+	if t.configWatcher != nil {
+		t.configWatcher.Stop()
+	}
+	// stop the task workers, the queued tasks are kept in the store
+	if t.tasks != nil {
+		t.tasks.Stop()
+	}
+	err := t.Server.Close()
+	// run the shutdown hooks, e.g. close the singletons
+	if hookErr := t.runShutdownHooks(); err == nil {
+		err = hookErr
+	}
+	// write out the buffered logs, and stop the async writer
+	_ = t.Logger.Close()
+	return err
 }
 
 // Shutdown stops the server gracefully.
@@ -132,24 +234,65 @@ func (t *Tong) Close() error {
 func (t *Tong) Shutdown(ctx context.Context) error {
 	// stop all cron jobs
 	t.stopCronJobs()
-	return t.Server.Shutdown(ctx)
+	if t.configWatcher != nil {
+		t.configWatcher.Stop()
+	}
+	err := t.Server.Shutdown(ctx)
+	// drain the queued tasks
+	if t.tasks != nil {
+		if drainErr := t.tasks.Drain(ctx); err == nil {
+			err = drainErr
+		}
+	}
+	// run the shutdown hooks, e.g. close the singletons
+	if hookErr := t.runShutdownHooks(); err == nil {
+		err = hookErr
+	}
+	// write out the buffered logs, and stop the async writer
+	_ = t.Logger.Close()
+	return err
 }
 
-// StartServer starts a custom http server.
+// StartServer starts a custom http server,
+// or writes the route table and exits in the inspection mode, see InspectRoutesEnv.
 func (t *Tong) StartServer(s *http.Server) error {
-	var err error
+	if path := os.Getenv(InspectRoutesEnv); path != "" {
+		t.inspectRoutes(path)
+	}
+	// the app run by `tong dev` is in debug mode
+	if url := os.Getenv(LiveReloadEnv); url != "" {
+		t.Debug = true
+		t.EnableLiveReload(url)
+	}
 	s.Handler = t
-	t.Listener, err = net.Listen("tcp", s.Addr)
+	// the listener is handed over by `tong dev`, see ListenFDEnv
+	listener, handoff, err := t.listen(s.Addr)
 	if err != nil {
 		return err
 	}
+	t.Listener = listener
+	// return once the requests are finished on SIGTERM, so the main goroutine never cuts them
+	defer handoff.wait()
+	if t.Clock != nil {
+		t.Logger.SetClock(t.Clock)
+	}
 	// start all cron jobs
 	t.startCronJobs()
+	// start the task workers, after the handlers are registered
+	if t.tasks != nil {
+		if err := t.tasks.Start(); err != nil {
+			t.Logger.ErrorFormat("start task queue: %v", err)
+		}
+	}
+	if t.tlsCertFile != "" {
+		return s.ServeTLS(t.Listener, t.tlsCertFile, t.tlsKeyFile)
+	}
 	return s.Serve(t.Listener)
 }
 
 func (t *Tong) NewContext(r *http.Request, w http.ResponseWriter) *Context {
 	return &Context{
+		tong:         t,
 		request:      r,
 		response:     NewResponse(w),
 		handler:      NotFoundHandler,
@@ -174,31 +317,166 @@ func (t *Tong) POST(p string, h HandlerFunc, m ...MiddlewareFunc) *RouteInfo {
 	return t.Add(http.MethodPost, p, h, m...)
 }
 
+func (t *Tong) PUT(p string, h HandlerFunc, m ...MiddlewareFunc) *RouteInfo {
+	return t.Add(http.MethodPut, p, h, m...)
+}
+
+func (t *Tong) PATCH(p string, h HandlerFunc, m ...MiddlewareFunc) *RouteInfo {
+	return t.Add(http.MethodPatch, p, h, m...)
+}
+
+func (t *Tong) DELETE(p string, h HandlerFunc, m ...MiddlewareFunc) *RouteInfo {
+	return t.Add(http.MethodDelete, p, h, m...)
+}
+
 func (t *Tong) Add(method, path string, handler HandlerFunc, middleware ...MiddlewareFunc) *RouteInfo {
-	name := handlerName(handler)
-	t.router.Add(method, path, func(c *Context) error {
+	r := t.router.Add(method, path, func(c *Context) error {
 		h := prependMiddleware(handler, middleware...)
 		return h(c)
 	})
-	r := &RouteInfo{
-		Method: method,
-		Path:   path,
-		Name:   name,
+	r.Name = handlerName(handler)
+	r.Middleware = make([]string, len(middleware))
+	for i, m := range middleware {
+		r.Middleware[i] = funcName(m)
+	}
+	// the types of a typed handler
+	if meta, ok := handlerMetaOf(handler); ok {
+		r.Name = meta.name
+		r.Request = meta.request
+		r.Responses = map[int]reflect.Type{http.StatusOK: meta.response}
 	}
-	t.router.routes[method+path] = r
 	return r
 }
 
-func (t *Tong) AddCronJob(initialPeriod, stepPeriod, maxPeriod time.Duration, job common.Job) {
+// SetTrustedProxies set the proxies whose X-Forwarded-For and X-Real-IP headers are trusted by Context.RealIP,
+// every proxy is an ip or a CIDR, e.g. 10.0.0.0/8.
+func (t *Tong) SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		} // if>>
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %s: %v", proxy, err)
+		} // if>>
+		nets = append(nets, ipNet)
+	} // for>
+	t.trustedProxies = nets
+	return nil
+}
+
+func (t *Tong) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range t.trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClock set the clock of the app, which drives the crons, the tasks and the logger.
+func (t *Tong) SetClock(clock common.Clock) {
+	t.Clock = clock
+	t.Logger.SetClock(clock)
+}
+
+// AddCronJob adds a job run by the period, and returns its Cron to be named,
+// the Clock, CronLocker and CronHistory of the app are applied when the jobs start.
+//
+// the name keys the locks and the run records of the job, it defaults to the type name of the job,
+// suffixed by #2, #3 and so on for the other jobs of the same type, in the order they are added.
+func (t *Tong) AddCronJob(initialPeriod, stepPeriod, maxPeriod time.Duration, job common.Job) *common.Cron {
 	c := common.NewCron(initialPeriod, stepPeriod, maxPeriod)
 	c.Do(job)
+	name := c.JobName()
+	for n := 2; t.cronJobNamed(name); n++ {
+		name = fmt.Sprintf("%s#%d", c.JobName(), n)
+	} // for>
+	c.Name(name)
 	t.cronList = append(t.cronList, c)
+	return c
 }
 
-func (t *Tong) startCronJobs() {
-	for i := range t.cronList {
-		t.cronList[i].Start()
+func (t *Tong) cronJobNamed(name string) bool {
+	for _, c := range t.cronList {
+		if c.JobName() == name {
+			return true
+		}
 	}
+	return false
+}
+
+// CronHistoryHandler returns a handler listing the run records of cron jobs,
+// filtered by the query parameters job, since, until (RFC3339) and limit.
+func (t *Tong) CronHistoryHandler() HandlerFunc {
+	return func(c *Context) error {
+		if t.CronHistory == nil {
+			return errors.New("cron history is not configured")
+		}
+		query := common.HistoryQuery{
+			Job:   c.QueryString("job", ""),
+			Limit: c.QueryInt("limit", 100),
+		}
+		var err error
+		if since := c.QueryString("since", ""); since != "" {
+			if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+				return NewHTTPError(http.StatusBadRequest, "invalid since: "+err.Error()).SetInternal(err)
+			}
+		}
+		if until := c.QueryString("until", ""); until != "" {
+			if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+				return NewHTTPError(http.StatusBadRequest, "invalid until: "+err.Error()).SetInternal(err)
+			}
+		}
+		records, err := t.CronHistory.Query(query)
+		if err != nil {
+			return err
+		}
+		return c.Json(http.StatusOK, records, "")
+	}
+}
+
+// Tasks returns the background task queue, it is created from TaskOptions on the first call,
+// and started with the server, so the persisted tasks are loaded after their handlers are registered.
+func (t *Tong) Tasks() *common.TaskQueue {
+	t.tasksOnce.Do(func() {
+		if t.TaskOptions.Clock == nil {
+			t.TaskOptions.Clock = t.Clock
+		}
+		t.tasks = common.NewTaskQueue(t.TaskOptions)
+	})
+	return t.tasks
+}
+
+func (t *Tong) startCronJobs() {
+	names := make(map[string]bool, len(t.cronList))
+	for _, c := range t.cronList {
+		// the jobs of a name share the locks, so only one of them would ever run
+		if names[c.JobName()] {
+			t.Logger.ErrorFormat("cron job %s is not started, the name is taken by another job", c.JobName())
+			continue
+		} // if>>
+		names[c.JobName()] = true
+		if t.Clock != nil {
+			c.SetClock(t.Clock)
+		}
+		if t.CronLocker != nil {
+			c.SetLocker(t.CronLocker)
+		}
+		if t.CronHistory != nil {
+			c.SetHistory(t.CronHistory)
+		}
+		c.Start()
+	} // for>
 }
 
 func (t *Tong) stopCronJobs() {
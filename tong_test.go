@@ -0,0 +1,195 @@
+package tong_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"github.com/ming3000/tong/tongtest"
+)
+
+func TestTong_Routing(t *testing.T) {
+	app := tongtest.NewApp()
+	app.GET("/fun", func(c *tong.Context) error {
+		return c.String(http.StatusOK, "get fun")
+	})
+	app.POST("/fun", func(c *tong.Context) error {
+		return c.String(http.StatusCreated, "post fun")
+	})
+	client := tongtest.NewClient(app)
+
+	client.GET("/fun").Do(t).ExpectStatus(http.StatusOK).ExpectBody("get fun").
+		ExpectHeader("Content-Type", "text/plain; charset=UTF-8")
+	client.POST("/fun").Do(t).ExpectStatus(http.StatusCreated).ExpectBody("post fun")
+	// a prefix of a route, an unknown route and an unknown method are not found
+	client.GET("/fu").Do(t).ExpectBody("handler not found")
+	client.GET("/funny").Do(t).ExpectBody("handler not found")
+	client.NewRequest(http.MethodDelete, "/fun").Do(t).ExpectBody("handler not found")
+}
+
+func TestTong_Middleware(t *testing.T) {
+	app := tongtest.NewApp()
+	order := make([]string, 0)
+	trace := func(name string) tong.MiddlewareFunc {
+		return func(next tong.HandlerFunc) tong.HandlerFunc {
+			return func(c *tong.Context) error {
+				order = append(order, name)
+				return next(c)
+			}
+		}
+	}
+	app.AddSysMiddleware(trace("sys"))
+	app.AddCustomerMiddleware(trace("customer"))
+	app.GET("/", func(c *tong.Context) error {
+		order = append(order, "handler")
+		return c.String(http.StatusOK, "ok")
+	}, trace("route"))
+
+	tongtest.NewClient(app).GET("/").Do(t).ExpectStatus(http.StatusOK)
+	if strings.Join(order, ",") != "sys,customer,route,handler" {
+		t.Fatal("unexpected middleware order", order)
+	}
+}
+
+func TestTong_ErrorHandler(t *testing.T) {
+	app := tongtest.NewApp()
+	app.GET("/fail", func(c *tong.Context) error {
+		return errors.New("boom")
+	})
+	client := tongtest.NewClient(app)
+	client.GET("/fail").Do(t).ExpectStatus(http.StatusInternalServerError).ExpectBody("boom")
+
+	app.HTTPErrorHandler = func(c *tong.Context, err error) {
+		_ = c.String(http.StatusTeapot, "custom "+err.Error())
+	}
+	client.GET("/fail").Do(t).ExpectStatus(http.StatusTeapot).ExpectBody("custom boom")
+}
+
+func TestTong_RequestID(t *testing.T) {
+	app := tongtest.NewApp()
+	app.GET("/", func(c *tong.Context) error {
+		return c.String(http.StatusOK, c.RequestID())
+	})
+	client := tongtest.NewClient(app)
+
+	client.GET("/").Header("X-Request-ID", "abc").Do(t).ExpectBody("abc").ExpectHeader("X-Request-ID", "abc")
+	res := client.GET("/").Do(t)
+	if len(res.Body()) != 32 || res.Header().Get("X-Request-ID") != res.Body() {
+		t.Fatal("request id should be generated", res.Body())
+	}
+	// an invalid request id is replaced
+	for _, id := range []string{"a b", "id\r\nX-Evil: 1", "<script>", strings.Repeat("a", 129)} {
+		res := client.GET("/").Header("X-Request-ID", id).Do(t)
+		if len(res.Body()) != 32 || res.Body() == id {
+			t.Errorf("request id %q is accepted as %q", id, res.Body())
+		}
+	}
+	client.GET("/").Header("X-Request-ID", "a.B_9-"+strings.Repeat("z", 122)).Do(t).ExpectBody("a.B_9-" + strings.Repeat("z", 122))
+}
+
+func TestTong_Form(t *testing.T) {
+	app := tongtest.NewApp()
+	app.POST("/user", func(c *tong.Context) error {
+		return c.Json(http.StatusOK, map[string]interface{}{
+			"name": c.PostString("name", "nobody"),
+			"age":  c.PostInt("age", 3),
+		}, "")
+	})
+	tongtest.NewClient(app).POST("/user").Form(url.Values{"name": {"tom"}, "age": {"30"}}).
+		Do(t).ExpectStatus(http.StatusOK).ExpectJSON(map[string]interface{}{"name": "tom", "age": 30})
+}
+
+func TestTong_Tasks(t *testing.T) {
+	store := common.NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	q := common.NewTaskQueue(common.TaskQueueOptions{Store: store})
+	q.Register("mail", func(payload []byte) error { return nil })
+	_ = q.Enqueue("mail", []byte("hi"), common.TaskOptions{})
+	q.Stop()
+
+	// the persisted task is not loaded until the queue starts
+	app := tongtest.NewApp()
+	app.TaskOptions = common.TaskQueueOptions{Store: store}
+	if m := app.Tasks().Metrics(); m.Pending != 0 {
+		t.Fatal("task queue should not start on the first call", m)
+	}
+	done := make(chan string, 1)
+	app.Tasks().Register("mail", func(payload []byte) error {
+		done <- string(payload)
+		return nil
+	})
+	if err := app.Tasks().Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Tasks().Stop()
+	select {
+	case payload := <-done:
+		if payload != "hi" {
+			t.Fatal("unexpected payload", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("persisted task should run with its handler")
+	}
+}
+
+func TestTong_CronHistoryHandler(t *testing.T) {
+	app := tongtest.NewApp()
+	app.CronHistory = common.NewFileHistory(filepath.Join(t.TempDir(), "cron.jsonl"), common.RetentionPolicy{})
+	_ = app.CronHistory.Append(common.RunRecord{Job: "mail", Start: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)})
+	app.GET("/history", app.CronHistoryHandler())
+	client := tongtest.NewClient(app)
+
+	var records []common.RunRecord
+	client.GET("/history").Query("since", "2019-12-31T00:00:00Z").Do(t).ExpectStatus(http.StatusOK).DecodeJSON(&records)
+	if len(records) != 1 || records[0].Job != "mail" {
+		t.Fatal("unexpected records", records)
+	}
+	client.GET("/history").Query("since", "yesterday").Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectBodyContains("invalid since")
+	client.GET("/history").Query("until", "2020-01-01").Do(t).
+		ExpectStatus(http.StatusBadRequest).ExpectBodyContains("invalid until")
+}
+
+// tickJob counts its runs
+type tickJob struct{ runs int32 }
+
+func (j *tickJob) Run() bool {
+	atomic.AddInt32(&j.runs, 1)
+	return false
+}
+
+func TestTong_CronJobNames(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	app := tongtest.NewApp()
+	app.SetClock(clock)
+	app.CronLocker = common.NewMemoryLocker().SetClock(clock)
+	a, b, c, d := &tickJob{}, &tickJob{}, &tickJob{}, &tickJob{}
+	ca := app.AddCronJob(10*time.Second, time.Second, time.Minute, a)
+	cb := app.AddCronJob(10*time.Second, time.Second, time.Minute, b)
+	if ca.JobName() != "*tong_test.tickJob" || cb.JobName() != "*tong_test.tickJob#2" {
+		t.Fatal("the jobs of a type should be named apart", ca.JobName(), cb.JobName())
+	}
+	// the names given twice are rejected
+	app.AddCronJob(10*time.Second, time.Second, time.Minute, c).Name("report")
+	app.AddCronJob(10*time.Second, time.Second, time.Minute, d).Name("report")
+
+	go func() { _ = app.Start("127.0.0.1:0") }()
+	defer app.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for atomic.LoadInt32(&a.runs) == 0 || atomic.LoadInt32(&b.runs) == 0 || atomic.LoadInt32(&c.runs) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("every job of a name should run with the locker", a.runs, b.runs, c.runs)
+		}
+		clock.Advance(time.Second)
+		time.Sleep(5 * time.Millisecond)
+	} // for>
+	if n := atomic.LoadInt32(&d.runs); n != 0 {
+		t.Fatal("the job of a taken name should not start", n)
+	}
+}
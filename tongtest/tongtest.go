@@ -0,0 +1,244 @@
+// Package tongtest drives Tong applications in-process for tests.
+//
+//	app := tong.New()
+//	app.GET("/users", listUsers)
+//	tongtest.NewClient(app).GET("/users").Query("page", "2").
+//		Do(t).ExpectStatus(http.StatusOK).ExpectJSON(expected)
+package tongtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ming3000/tong"
+	"github.com/ming3000/tong/common"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// NewApp returns a new Tong instance whose logger discards the output,
+// so the tests do not write log files.
+func NewApp() *tong.Tong {
+	app := tong.New()
+	app.Logger = common.NewLoggerWithSinks("", true)
+	return app
+}
+
+// NewRequest returns a request for the target, as an incoming server request.
+func NewRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body)
+}
+
+// NewContext returns a context of the app for the request,
+// so a single HandlerFunc or middleware can be unit-tested.
+func NewContext(app *tong.Tong, r *http.Request) (*tong.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	return app.NewContext(r, w), w
+}
+
+// RunHandler runs the handler wrapped by the middleware with a new context,
+// the error is returned as is, without the error handler of the app.
+func RunHandler(app *tong.Tong, r *http.Request, h tong.HandlerFunc, middleware ...tong.MiddlewareFunc) (*httptest.ResponseRecorder, error) {
+	c, w := NewContext(app, r)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return w, h(c)
+}
+
+// $--- client ---
+// Client sends requests to an app through Tong.ServeHTTP.
+type Client struct {
+	app    *tong.Tong
+	header http.Header
+}
+
+// NewClient returns a new Client instance of the app.
+func NewClient(app *tong.Tong) *Client {
+	return &Client{app: app, header: http.Header{}}
+}
+
+// SetHeader sets a header sent with every request of the client.
+func (c *Client) SetHeader(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+func (c *Client) GET(path string) *Request {
+	return c.NewRequest(http.MethodGet, path)
+}
+
+func (c *Client) POST(path string) *Request {
+	return c.NewRequest(http.MethodPost, path)
+}
+
+// NewRequest returns a request builder of the method and path.
+func (c *Client) NewRequest(method, path string) *Request {
+	header := http.Header{}
+	for k, v := range c.header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &Request{client: c, method: method, path: path, header: header, query: url.Values{}}
+}
+
+// $--- request ---
+// Request builds a request fluently.
+type Request struct {
+	client *Client
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	err    error
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Form sends the values as an urlencoded form body.
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body(strings.NewReader(values.Encode()), common.MIMEApplicationForm)
+}
+
+// JSON sends the value marshaled as a JSON body.
+func (r *Request) JSON(value interface{}) *Request {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body(bytes.NewReader(data), common.MIMEApplicationJSON)
+}
+
+// Body sends the body with the content type.
+func (r *Request) Body(body io.Reader, contentType string) *Request {
+	r.body = body
+	r.header.Set(common.HeaderContentType, contentType)
+	return r
+}
+
+// Build returns the http request.
+func (r *Request) Build() *http.Request {
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req := NewRequest(r.method, target, r.body)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	return req
+}
+
+// Do serves the request in-process, and returns the response for assertions.
+func (r *Request) Do(t testing.TB) *Response {
+	t.Helper()
+	if r.err != nil {
+		t.Fatalf("build request %s %s: %v", r.method, r.path, r.err)
+	}
+	w := httptest.NewRecorder()
+	r.client.app.ServeHTTP(w, r.Build())
+	return &Response{t: t, Recorder: w, name: r.method + " " + r.path}
+}
+
+// $--- response ---
+// Response asserts on the served response, a failed assertion reports an error of the test.
+type Response struct {
+	Recorder *httptest.ResponseRecorder
+	t        testing.TB
+	name     string
+}
+
+// Code returns the status code.
+func (r *Response) Code() int {
+	return r.Recorder.Code
+}
+
+// Header returns the header as sent to the client.
+func (r *Response) Header() http.Header {
+	return r.Recorder.Result().Header
+}
+
+// Body returns the body as a string.
+func (r *Response) Body() string {
+	return r.Recorder.Body.String()
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code() != code {
+		r.t.Errorf("%s: status = %d, want %d, body: %s", r.name, r.Code(), code, r.Body())
+	}
+	return r
+}
+
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("%s: header %s = %q, want %q", r.name, key, got, value)
+	}
+	return r
+}
+
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	if r.Body() != body {
+		r.t.Errorf("%s: body = %q, want %q", r.name, r.Body(), body)
+	}
+	return r
+}
+
+func (r *Response) ExpectBodyContains(s string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Body(), s) {
+		r.t.Errorf("%s: body %q does not contain %q", r.name, r.Body(), s)
+	}
+	return r
+}
+
+// ExpectJSON compares the JSON body with the value marshaled,
+// regardless of the key order and the indent.
+func (r *Response) ExpectJSON(value interface{}) *Response {
+	r.t.Helper()
+	var got, want interface{}
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), &got); err != nil {
+		r.t.Errorf("%s: body is not JSON: %v, body: %s", r.name, err, r.Body())
+		return r
+	}
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, &want)
+	}
+	if err != nil {
+		r.t.Errorf("%s: marshal expected value: %v", r.name, err)
+		return r
+	}
+	if !reflect.DeepEqual(got, want) {
+		r.t.Errorf("%s: JSON body = %s, want %s", r.name, strings.TrimSpace(r.Body()), data)
+	}
+	return r
+}
+
+// DecodeJSON unmarshals the body into the value.
+func (r *Response) DecodeJSON(value interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), value); err != nil {
+		r.t.Errorf("%s: decode JSON body: %v, body: %s", r.name, err, r.Body())
+	}
+	return r
+}
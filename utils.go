@@ -1,6 +1,10 @@
 package tong
 
-import "net/http"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
 
 // fix the input path
 func fixPath(path string) string {
@@ -22,3 +26,25 @@ func parsePath(r *http.Request) string {
 	}
 	return path
 }
+
+// generate a random request id
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// a request id from the client is logged and echoed,
+// so it is at most 128 of [A-Za-z0-9._-]
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	} // if>
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		} // if>>
+	} // for>
+	return true
+}